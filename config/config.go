@@ -0,0 +1,302 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config 起動時に読み込む設定
+type Config struct {
+	Elasticsearch ElasticsearchConfig `yaml:"elasticsearch"`
+	Sentry        SentryConfig        `yaml:"sentry"`
+	Server        ServerConfig        `yaml:"server"`
+	Search        SearchConfig        `yaml:"search"`
+	AccessEvents  AccessEventsConfig  `yaml:"access_events"`
+}
+
+// ElasticsearchConfig 接続するElasticsearchの設定
+type ElasticsearchConfig struct {
+	// URL 接続先、必須
+	URL string `yaml:"url"`
+}
+
+// SentryConfig エラーを送信するSentryの設定
+type SentryConfig struct {
+	// DSN 送信先、空の場合は送信しない
+	DSN string `yaml:"dsn"`
+}
+
+// ServerConfig ルーティングとHTTPサーバーとして起動した場合の設定
+type ServerConfig struct {
+	// Address HTTPサーバーとして起動した場合に待ち受けるアドレス
+	Address string `yaml:"address"`
+	// AllowOrigins CORSで許可するオリジン
+	AllowOrigins []string `yaml:"allow_origins"`
+	// RequestTimeout リクエストの時間の上限
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+	// RequestTimeoutMargin Lambdaの実行期限までにレスポンスの返却と溜めたアクセスの書き込みを行うために残す時間
+	RequestTimeoutMargin time.Duration `yaml:"request_timeout_margin"`
+	// ShutdownTimeout HTTPサーバーの終了時に処理中のリクエストを待つ時間
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// SearchConfig 検索の設定
+type SearchConfig struct {
+	Indices IndicesConfig `yaml:"indices"`
+	// DefaultLimit 商品の一覧でlimitを指定しない場合の件数
+	DefaultLimit int `yaml:"default_limit"`
+	// ClassificationLimit 分類の一覧でlimitを指定しない場合の件数
+	ClassificationLimit int `yaml:"classification_limit"`
+	// PopularityHalfLife 人気順でアクセス回数の評価が半分になる期間
+	PopularityHalfLife time.Duration `yaml:"popularity_half_life"`
+	// TrendingHalfLife 急上昇順でアクセス回数の評価が半分になる期間
+	TrendingHalfLife time.Duration `yaml:"trending_half_life"`
+	// RecommendFallbacks おすすめ商品が見つからない場合に順に試す方法、指定しない場合は既定の方法を使い、空のリストを指定した場合のみ使わない
+	RecommendFallbacks []string `yaml:"recommend_fallbacks"`
+	// ClassificationSources classification-infoのindexで指定できる分類、設定ファイルや環境変数の分類は既定の分類に追加する
+	ClassificationSources map[string]ClassificationSourceConfig `yaml:"classification_sources"`
+}
+
+// IndicesConfig 検索するインデックスの名前
+type IndicesConfig struct {
+	Items        string `yaml:"items"`
+	Categories   string `yaml:"categories"`
+	Brands       string `yaml:"brands"`
+	AccessEvents string `yaml:"access_events"`
+}
+
+// ClassificationSourceConfig classification-infoのindexで指定できる分類の設定
+type ClassificationSourceConfig struct {
+	Index      string   `json:"index" yaml:"index"`
+	Filters    []string `json:"filters" yaml:"filters"`
+	Sort       string   `json:"sort" yaml:"sort"`
+	Descending bool     `json:"descending" yaml:"descending"`
+}
+
+// AccessEventsConfig 商品へのアクセスを溜めてまとめて書き込む設定
+type AccessEventsConfig struct {
+	// BufferSize 溜めたアクセスをまとめて書き込む件数
+	BufferSize int `yaml:"buffer_size"`
+	// FlushInterval HTTPサーバーとして起動した場合に溜めたアクセスを書き込む間隔
+	FlushInterval time.Duration `yaml:"flush_interval"`
+}
+
+// Default 設定ファイルや環境変数で指定されていない項目の既定値、全ての既定値はここで設定する
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Address:              ":8080",
+			AllowOrigins:         []string{"*"},
+			RequestTimeout:       30 * time.Second,
+			RequestTimeoutMargin: time.Second,
+			ShutdownTimeout:      30 * time.Second,
+		},
+		Search: SearchConfig{
+			Indices: IndicesConfig{
+				Items:        "items",
+				Categories:   "categories",
+				Brands:       "brands",
+				AccessEvents: "item_access_events",
+			},
+			DefaultLimit:        36,
+			ClassificationLimit: 100,
+			PopularityHalfLife:  30 * 24 * time.Hour,
+			TrendingHalfLife:    3 * 24 * time.Hour,
+			RecommendFallbacks:  []string{"same_gender", "popular"},
+			ClassificationSources: map[string]ClassificationSourceConfig{
+				"categories": {Filters: []string{"gender", "title", "parent_id"}},
+				"brands":     {Filters: []string{"gender", "title"}},
+			},
+		},
+		AccessEvents: AccessEventsConfig{
+			BufferSize:    100,
+			FlushInterval: 10 * time.Second,
+		},
+	}
+}
+
+// Load 既定値を設定ファイル、環境変数の順に上書きして検証する、pathが空の場合は設定ファイルを読まない
+// 設定ファイルはYAMLで、JSONも読み込める
+func Load(path string) (Config, error) {
+	config := Default()
+	if len(path) > 0 {
+		if err := config.loadFile(path); err != nil {
+			return Config{}, err
+		}
+	}
+	if err := config.loadEnv(os.LookupEnv); err != nil {
+		return Config{}, err
+	}
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+func (config *Config) loadFile(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	// 設定の誤りに気付けるよう存在しない項目はエラーとする
+	if err := yaml.UnmarshalStrict(b, config); err != nil {
+		return fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// envLoader 環境変数を型に変換し、最初のエラーを記録する
+type envLoader struct {
+	lookup func(string) (string, bool)
+	err    error
+}
+
+func (l *envLoader) string(name string, value *string) {
+	if v, ok := l.lookup(name); ok && len(v) > 0 {
+		*value = v
+	}
+}
+
+func (l *envLoader) strings(name string, value *[]string) {
+	if v, ok := l.lookup(name); ok && len(v) > 0 {
+		*value = strings.Split(v, ",")
+	}
+}
+
+func (l *envLoader) int(name string, value *int) {
+	if v, ok := l.lookup(name); ok && len(v) > 0 {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			l.fail(name, err)
+			return
+		}
+		*value = i
+	}
+}
+
+func (l *envLoader) duration(name string, value *time.Duration) {
+	if v, ok := l.lookup(name); ok && len(v) > 0 {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			l.fail(name, err)
+			return
+		}
+		*value = d
+	}
+}
+
+func (l *envLoader) fail(name string, err error) {
+	if l.err == nil {
+		l.err = fmt.Errorf("invalid environment variable %s: %v", name, err)
+	}
+}
+
+func (config *Config) loadEnv(lookup func(string) (string, bool)) error {
+	l := &envLoader{lookup: lookup}
+	l.string("ELASTICSEARCH_SERVICE_HOST_NAME", &config.Elasticsearch.URL)
+	l.string("SENTRY_DSN", &config.Sentry.DSN)
+
+	l.string("SERVER_ADDRESS", &config.Server.Address)
+	l.strings("CORS_ALLOW_ORIGINS", &config.Server.AllowOrigins)
+	l.duration("REQUEST_TIMEOUT", &config.Server.RequestTimeout)
+	l.duration("REQUEST_TIMEOUT_MARGIN", &config.Server.RequestTimeoutMargin)
+	l.duration("SHUTDOWN_TIMEOUT", &config.Server.ShutdownTimeout)
+
+	l.string("ITEMS_INDEX", &config.Search.Indices.Items)
+	l.string("CATEGORIES_INDEX", &config.Search.Indices.Categories)
+	l.string("BRANDS_INDEX", &config.Search.Indices.Brands)
+	l.string("ACCESS_EVENTS_INDEX", &config.Search.Indices.AccessEvents)
+	l.int("DEFAULT_LIMIT", &config.Search.DefaultLimit)
+	l.int("CLASSIFICATION_LIMIT", &config.Search.ClassificationLimit)
+	l.duration("POPULARITY_HALF_LIFE", &config.Search.PopularityHalfLife)
+	l.duration("TRENDING_HALF_LIFE", &config.Search.TrendingHalfLife)
+	// noneの場合はfallbackを使わない
+	if v, ok := lookup("RECOMMEND_FALLBACKS"); ok && v == "none" {
+		config.Search.RecommendFallbacks = []string{}
+	} else {
+		l.strings("RECOMMEND_FALLBACKS", &config.Search.RecommendFallbacks)
+	}
+	// 追加する分類のJSON、例: {"colors":{"filters":["title"],"sort":"title"}}
+	if v, ok := lookup("CLASSIFICATION_SOURCES"); ok && len(v) > 0 {
+		var sources map[string]ClassificationSourceConfig
+		if err := json.Unmarshal([]byte(v), &sources); err != nil {
+			l.fail("CLASSIFICATION_SOURCES", err)
+		}
+		if config.Search.ClassificationSources == nil {
+			config.Search.ClassificationSources = make(map[string]ClassificationSourceConfig, len(sources))
+		}
+		for name, source := range sources {
+			config.Search.ClassificationSources[name] = source
+		}
+	}
+
+	l.int("ACCESS_EVENT_BUFFER_SIZE", &config.AccessEvents.BufferSize)
+	l.duration("ACCESS_EVENT_FLUSH_INTERVAL", &config.AccessEvents.FlushInterval)
+	return l.err
+}
+
+// Validate 必須の項目と値の範囲を検証し、不正な項目を全て含むエラーを返却する
+// 検索の方法や分類の名前は検索の設定に変換した後に検証する
+func (config *Config) Validate() error {
+	var problems []string
+	if len(config.Elasticsearch.URL) == 0 {
+		problems = append(problems, "elasticsearch.url is required")
+	} else if u, err := url.Parse(config.Elasticsearch.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		problems = append(problems, "elasticsearch.url must be an http or https URL")
+	}
+	if len(config.Server.Address) == 0 {
+		problems = append(problems, "server.address is required")
+	}
+	if len(config.Server.AllowOrigins) == 0 {
+		problems = append(problems, "server.allow_origins is required")
+	}
+	if config.Server.RequestTimeout <= 0 {
+		problems = append(problems, "server.request_timeout must be greater than 0")
+	}
+	if config.Server.RequestTimeoutMargin < 0 {
+		problems = append(problems, "server.request_timeout_margin must not be negative")
+	}
+	if config.Server.ShutdownTimeout <= 0 {
+		problems = append(problems, "server.shutdown_timeout must be greater than 0")
+	}
+	for _, index := range [][2]string{
+		{"items", config.Search.Indices.Items},
+		{"categories", config.Search.Indices.Categories},
+		{"brands", config.Search.Indices.Brands},
+		{"access_events", config.Search.Indices.AccessEvents},
+	} {
+		if len(index[1]) == 0 {
+			problems = append(problems, fmt.Sprintf("search.indices.%s is required", index[0]))
+		}
+	}
+	if config.Search.DefaultLimit <= 0 {
+		problems = append(problems, "search.default_limit must be greater than 0")
+	}
+	if config.Search.ClassificationLimit <= 0 {
+		problems = append(problems, "search.classification_limit must be greater than 0")
+	}
+	if config.Search.PopularityHalfLife <= 0 {
+		problems = append(problems, "search.popularity_half_life must be greater than 0")
+	}
+	if config.Search.TrendingHalfLife <= 0 {
+		problems = append(problems, "search.trending_half_life must be greater than 0")
+	}
+	if config.AccessEvents.BufferSize <= 0 {
+		problems = append(problems, "access_events.buffer_size must be greater than 0")
+	}
+	if config.AccessEvents.FlushInterval <= 0 {
+		problems = append(problems, "access_events.flush_interval must be greater than 0")
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(problems, ", "))
+	}
+	return nil
+}
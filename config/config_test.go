@@ -0,0 +1,180 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatalf("temp dir error:%v", err)
+	}
+	return dir
+}
+
+func writeConfigFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("write error:%v", err)
+	}
+	return path
+}
+
+func envLookup(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	testCase := func(name, content string) {
+		t.Helper()
+		config := Default()
+		if err := config.loadFile(writeConfigFile(t, dir, name, content)); err != nil {
+			t.Fatalf("load error:%v", err)
+		}
+		if config.Elasticsearch.URL != "https://search.example.com" || config.Server.RequestTimeout != 10*time.Second {
+			t.Errorf("config error:%+v", config)
+		}
+		if config.Search.Indices.Items != "items_v2" || config.Search.DefaultLimit != 24 || config.Search.PopularityHalfLife != 7*24*time.Hour {
+			t.Errorf("search config error:%+v", config.Search)
+		}
+		// 既定の分類に追加される
+		if _, ok := config.Search.ClassificationSources["categories"]; !ok || len(config.Search.ClassificationSources) != 3 || config.Search.ClassificationSources["colors"].Sort != "title" {
+			t.Errorf("classification sources error:%+v", config.Search.ClassificationSources)
+		}
+	}
+	testCase("config.yaml", `
+elasticsearch:
+  url: https://search.example.com
+server:
+  request_timeout: 10s
+search:
+  indices:
+    items: items_v2
+  default_limit: 24
+  popularity_half_life: 168h
+  classification_sources:
+    colors:
+      filters: [title]
+      sort: title
+`)
+	testCase("config.json", `{
+  "elasticsearch": {"url": "https://search.example.com"},
+  "server": {"request_timeout": "10s"},
+  "search": {
+    "indices": {"items": "items_v2"},
+    "default_limit": 24,
+    "popularity_half_life": "168h",
+    "classification_sources": {"colors": {"filters": ["title"], "sort": "title"}}
+  }
+}`)
+
+	config := Default()
+	if err := config.loadFile(writeConfigFile(t, dir, "config.yaml", "search:\n  default_size: 24\n")); err == nil {
+		t.Errorf("unknown config accepted")
+	}
+
+	// 空のリストを指定した場合のみfallbackを使わない
+	config = Default()
+	if err := config.loadFile(writeConfigFile(t, dir, "config.yaml", "search:\n  recommend_fallbacks: []\n")); err != nil || config.Search.RecommendFallbacks == nil || len(config.Search.RecommendFallbacks) != 0 {
+		t.Errorf("empty recommend fallbacks error:%v %v", config.Search.RecommendFallbacks, err)
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	config := Default()
+	config.Search.DefaultLimit = 24
+	config.Search.ClassificationSources = map[string]ClassificationSourceConfig{"sizes": {Filters: []string{"title"}}}
+	err := config.loadEnv(envLookup(map[string]string{
+		"ELASTICSEARCH_SERVICE_HOST_NAME": "https://search.example.com",
+		"CORS_ALLOW_ORIGINS":              "https://a.example.com,https://b.example.com",
+		"BRANDS_INDEX":                    "brands_v2",
+		"DEFAULT_LIMIT":                   "48",
+		"TRENDING_HALF_LIFE":              "24h",
+		"RECOMMEND_FALLBACKS":             "none",
+		"CLASSIFICATION_SOURCES":          `{"colors":{"filters":["title"]}}`,
+		"ACCESS_EVENT_FLUSH_INTERVAL":     "1m",
+	}))
+	if err != nil {
+		t.Fatalf("load error:%v", err)
+	}
+	if !reflect.DeepEqual(config.Server.AllowOrigins, []string{"https://a.example.com", "https://b.example.com"}) {
+		t.Errorf("allow origins error:%v", config.Server.AllowOrigins)
+	}
+	// 環境変数は設定ファイルの値を上書きする
+	if config.Search.Indices.Brands != "brands_v2" || config.Search.DefaultLimit != 48 || config.Search.TrendingHalfLife != 24*time.Hour {
+		t.Errorf("search config error:%+v", config.Search)
+	}
+	if config.Search.RecommendFallbacks == nil || len(config.Search.RecommendFallbacks) != 0 {
+		t.Errorf("recommend fallbacks error:%v", config.Search.RecommendFallbacks)
+	}
+	// 設定ファイルの分類に追加される
+	if len(config.Search.ClassificationSources) != 2 || len(config.Search.ClassificationSources["colors"].Filters) != 1 {
+		t.Errorf("classification sources error:%v", config.Search.ClassificationSources)
+	}
+	if config.AccessEvents.BufferSize != 100 || config.AccessEvents.FlushInterval != time.Minute {
+		t.Errorf("access events config error:%+v", config.AccessEvents)
+	}
+
+	config = Default()
+	if err := config.loadEnv(envLookup(map[string]string{"REQUEST_TIMEOUT": "30"})); err == nil || !strings.Contains(err.Error(), "REQUEST_TIMEOUT") {
+		t.Errorf("invalid duration accepted:%v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	config := Default()
+	config.Elasticsearch.URL = "https://search.example.com"
+	if err := config.Validate(); err != nil {
+		t.Errorf("validate error:%v", err)
+	}
+
+	config = Default()
+	config.Server.RequestTimeout = 0
+	config.Search.Indices.Brands = ""
+	config.Search.DefaultLimit = -1
+	config.Search.TrendingHalfLife = 0
+	config.AccessEvents.BufferSize = 0
+	err := config.Validate()
+	expected := "invalid config: elasticsearch.url is required, server.request_timeout must be greater than 0, search.indices.brands is required, search.default_limit must be greater than 0, search.trending_half_life must be greater than 0, access_events.buffer_size must be greater than 0"
+	if err == nil || err.Error() != expected {
+		t.Errorf("validate error:%v", err)
+	}
+
+	config = Default()
+	config.Elasticsearch.URL = "search.example.com"
+	if err := config.Validate(); err == nil {
+		t.Errorf("invalid url accepted")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := writeConfigFile(t, dir, "config.yaml", "elasticsearch:\n  url: https://search.example.com\n")
+	if os.Getenv("ELASTICSEARCH_SERVICE_HOST_NAME") != "" {
+		t.Skip("ELASTICSEARCH_SERVICE_HOST_NAME is set")
+	}
+	config, err := Load(path)
+	if err != nil {
+		t.Fatalf("load error:%v", err)
+	}
+	if config.Elasticsearch.URL != "https://search.example.com" || config.Server.Address != ":8080" || config.Search.DefaultLimit != 36 || len(config.Search.RecommendFallbacks) != 2 {
+		t.Errorf("config error:%+v", config)
+	}
+	if _, err := Load(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Errorf("missing config file accepted")
+	}
+}
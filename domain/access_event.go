@@ -0,0 +1,14 @@
+package domain
+
+import (
+	"time"
+)
+
+// AccessEvent 商品へのアクセス、同じ商品へのアクセスはCountに集約する
+type AccessEvent struct {
+	ItemID     string    `json:"item_id"`
+	Count      int       `json:"count"`
+	AccessedAt time.Time `json:"accessed_at"`
+	// SessionID 同じ人が閲覧した商品を集計するためのセッションまたはユーザーの識別子
+	SessionID string `json:"session_id,omitempty"`
+}
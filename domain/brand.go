@@ -0,0 +1,17 @@
+package domain
+
+// BrandDetail ブランドの情報と商品の集計
+type BrandDetail struct {
+	Version int             `json:"version"`
+	Brand   *Classification `json:"brand"`
+	// ItemCount ブランドの商品数
+	ItemCount int64 `json:"item_count"`
+	// MinPrice, MaxPrice 商品の最低価格の範囲、商品がない場合はnull
+	MinPrice *int `json:"min_price"`
+	MaxPrice *int `json:"max_price"`
+	// Categories, Genders 商品のカテゴリ・性別毎の件数
+	Categories []FacetBucket `json:"categories"`
+	Genders    []FacetBucket `json:"genders"`
+	// DiscountShare 値引きしている商品の割合
+	DiscountShare float64 `json:"discount_share"`
+}
@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+)
+
+// Cursor 検索結果の続きを取得するための並び順と最後の商品の並び順の値
+type Cursor struct {
+	// Order カーソルを返却した検索の並び順、異なる並び順の検索には使えない
+	Order string `json:"order"`
+	// After 最後の商品の並び順の値
+	After []interface{} `json:"after"`
+	// Origin 人気順・急上昇順でスコアを減衰させる基準の日時(ミリ秒)、ページ毎にスコアが変わらないよう最初のページの日時を引き継ぐ
+	Origin int64 `json:"origin,omitempty"`
+}
+
+// String クライアントに返却する文字列に変換する
+func (c Cursor) String() string {
+	if len(c.After) == 0 {
+		return ""
+	}
+	j, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(j)
+}
+
+// ParseCursor クライアントから受け取った文字列をCursorに変換する
+func ParseCursor(s string) (Cursor, error) {
+	j, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, NewValidationError("cursor", "is invalid")
+	}
+	// 日時の並び順の値は桁数が大きいためfloat64に変換せずそのまま保持する
+	decoder := json.NewDecoder(bytes.NewReader(j))
+	decoder.UseNumber()
+	var cursor Cursor
+	if err := decoder.Decode(&cursor); err != nil || len(cursor.Order) == 0 || len(cursor.After) == 0 {
+		return Cursor{}, NewValidationError("cursor", "is invalid")
+	}
+	return cursor, nil
+}
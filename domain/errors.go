@@ -0,0 +1,93 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NotFoundError 指定された商品などが存在しない
+type NotFoundError struct {
+	Resource string `json:"resource"`
+	ID       string `json:"id"`
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s not found: %s", e.Resource, e.ID)
+}
+
+// UnavailableError 検索エンジンなどの依存先に接続できない、または依存先がエラーを返した
+type UnavailableError struct {
+	Service string
+	Err     error
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("%s unavailable: %v", e.Service, e.Err)
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// TimeoutError 依存先の応答が時間内に返らない
+type TimeoutError struct {
+	Service string
+	Err     error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timeout: %v", e.Service, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// CanceledError クライアントの切断などで依存先への要求が中断された、依存先の障害ではない
+type CanceledError struct {
+	Service string
+	Err     error
+}
+
+func (e *CanceledError) Error() string {
+	return fmt.Sprintf("%s canceled: %v", e.Service, e.Err)
+}
+
+func (e *CanceledError) Unwrap() error {
+	return e.Err
+}
+
+// ConflictError 同じものが同時に更新されたため処理できない
+type ConflictError struct {
+	Resource string `json:"resource"`
+	ID       string `json:"id"`
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s conflict: %s", e.Resource, e.ID)
+}
+
+// FieldError 不正なパラメータとその理由
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError パラメータの検証エラー、不正なパラメータを全て含む
+type ValidationError struct {
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields"`
+}
+
+// NewValidationError パラメータが一つだけ不正な場合のエラー
+func NewValidationError(field, message string) *ValidationError {
+	return &ValidationError{Message: "invalid parameters", Fields: []FieldError{{Field: field, Message: message}}}
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		fields = append(fields, fmt.Sprintf("%s %s", field.Field, field.Message))
+	}
+	return fmt.Sprintf("%s: %s", e.Message, strings.Join(fields, ", "))
+}
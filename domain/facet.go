@@ -0,0 +1,30 @@
+package domain
+
+// 絞り込み件数を返却できる項目名
+const (
+	FacetBrand        = "brand"
+	FacetCategory     = "category"
+	FacetGender       = "gender"
+	FacetDiscountFlag = "discount_flag"
+	FacetPrice        = "price"
+)
+
+// FacetNames 絞り込み件数を返却できる項目の一覧
+var FacetNames = Choices{FacetBrand, FacetCategory, FacetGender, FacetDiscountFlag, FacetPrice}
+
+// FacetBucket struct
+type FacetBucket struct {
+	Key   string   `json:"key"`
+	From  *float64 `json:"from,omitempty"`
+	To    *float64 `json:"to,omitempty"`
+	Count int64    `json:"count"`
+}
+
+// Facets struct
+type Facets struct {
+	Brand        []FacetBucket `json:"brand,omitempty"`
+	Category     []FacetBucket `json:"category,omitempty"`
+	Gender       []FacetBucket `json:"gender,omitempty"`
+	DiscountFlag []FacetBucket `json:"discount_flag,omitempty"`
+	Price        []FacetBucket `json:"price,omitempty"`
+}
@@ -4,11 +4,28 @@ import (
 	"time"
 )
 
+// SKU struct
+type SKU struct {
+	Size  string  `json:"size"`
+	Bmi   float64 `json:"bmi"`
+	Stock int     `json:"stock"`
+}
+
 // Item struct
 type Item struct {
 	ItemID         string    `json:"item_id"`
+	Title          string    `json:"title"`
+	Description    string    `json:"description,omitempty"`
+	Brand          string    `json:"brand"`
 	Gender         string    `json:"gender"`
 	Category       string    `json:"category"`
+	LowestPrice    int       `json:"lowest_price"`
+	HighestPrice   int       `json:"highest_price"`
+	DiscountFlag   int       `json:"discount_flag"`
+	ReleaseFlag    int       `json:"release_flag"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	Images         []string  `json:"images"`
+	SKUs           []SKU     `json:"SKUs"`
 	AccessCounter  int       `json:"access_counter"`
 	LastAccessedAt time.Time `json:"last_accessed_at"`
 }
@@ -0,0 +1,266 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// KeywordFields キーワードで「項目名:値」の形式で指定できる項目
+var KeywordFields = []string{"brand", "category", "gender"}
+
+type keywordTokenKind int
+
+const (
+	tokenWord keywordTokenKind = iota
+	tokenPhrase
+	tokenOr
+	tokenNot
+	tokenOpen
+	tokenClose
+)
+
+type keywordToken struct {
+	kind  keywordTokenKind
+	field string
+	text  string
+	pos   int
+}
+
+// KeywordError キーワードの構文エラー
+type KeywordError struct {
+	Pos     int    `json:"pos"`
+	Message string `json:"message"`
+}
+
+func (e *KeywordError) Error() string {
+	return fmt.Sprintf("invalid keywords at %d: %s", e.Pos, e.Message)
+}
+
+func isKeywordDelimiter(r rune) bool {
+	return unicode.IsSpace(r) || r == '(' || r == ')' || r == '/' || r == '"'
+}
+
+func keywordField(word string) (string, string) {
+	for _, field := range KeywordFields {
+		if strings.HasPrefix(word, field+":") {
+			return field, word[len(field)+1:]
+		}
+	}
+	return "", word
+}
+
+// tokenizeKeywords キーワードを字句に分解する
+// 「-」は語の先頭にある場合のみ除外として扱い、「T-シャツ」のような語の途中のものは語の一部とする
+// keywordsは正規化済みの文字列とする
+func tokenizeKeywords(keywords string) ([]keywordToken, error) {
+	runes := []rune(keywords)
+	var tokens []keywordToken
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, keywordToken{kind: tokenOpen, pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, keywordToken{kind: tokenClose, pos: i})
+			i++
+		case r == '/':
+			tokens = append(tokens, keywordToken{kind: tokenOr, pos: i})
+			i++
+		case r == '-':
+			if i+1 >= len(runes) || unicode.IsSpace(runes[i+1]) || runes[i+1] == ')' || runes[i+1] == '/' {
+				return nil, &KeywordError{Pos: i, Message: "missing keyword after -"}
+			}
+			tokens = append(tokens, keywordToken{kind: tokenNot, pos: i})
+			i++
+		case r == '"':
+			phrase, end, err := readKeywordPhrase(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, keywordToken{kind: tokenPhrase, text: phrase, pos: i})
+			i = end
+		default:
+			start := i
+			for i < len(runes) && !isKeywordDelimiter(runes[i]) {
+				i++
+			}
+			field, text := keywordField(string(runes[start:i]))
+			if field != "" && len(text) == 0 {
+				// 「brand:"THE NORTH FACE"」のように値を引用符で指定する場合
+				if i >= len(runes) || runes[i] != '"' {
+					return nil, &KeywordError{Pos: start, Message: fmt.Sprintf("missing value for %s", field)}
+				}
+				phrase, end, err := readKeywordPhrase(runes, i)
+				if err != nil {
+					return nil, err
+				}
+				text = phrase
+				i = end
+			}
+			tokens = append(tokens, keywordToken{kind: tokenWord, field: field, text: text, pos: start})
+		}
+	}
+	return tokens, nil
+}
+
+func readKeywordPhrase(runes []rune, start int) (string, int, error) {
+	for i := start + 1; i < len(runes); i++ {
+		if runes[i] == '"' {
+			phrase := strings.Join(strings.Fields(string(runes[start+1:i])), " ")
+			if len(phrase) == 0 {
+				return "", 0, &KeywordError{Pos: start, Message: "empty phrase"}
+			}
+			return phrase, i + 1, nil
+		}
+	}
+	return "", 0, &KeywordError{Pos: start, Message: "unterminated quote"}
+}
+
+// KeywordNode キーワードの構文木、KeywordTerm, KeywordAnd, KeywordOr, KeywordNotのいずれか
+// 検索の条件への変換や商品に対する評価はリポジトリで行う
+type KeywordNode interface {
+	keywordNode()
+}
+
+// KeywordTerm 語句、Fieldがある場合は項目の完全一致
+type KeywordTerm struct {
+	Field string
+	Text  string
+}
+
+// KeywordAnd 全ての条件に一致する
+type KeywordAnd struct {
+	Children []KeywordNode
+}
+
+// KeywordOr いずれかの条件に一致する
+type KeywordOr struct {
+	Children []KeywordNode
+}
+
+// KeywordNot 条件に一致しない
+type KeywordNot struct {
+	Child KeywordNode
+}
+
+func (*KeywordTerm) keywordNode() {}
+func (*KeywordAnd) keywordNode()  {}
+func (*KeywordOr) keywordNode()   {}
+func (*KeywordNot) keywordNode()  {}
+
+type keywordParser struct {
+	tokens []keywordToken
+	pos    int
+	length int
+}
+
+func (p *keywordParser) peek() *keywordToken {
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.pos]
+}
+
+// parseAnd 空白区切りの条件をANDとして評価する
+func (p *keywordParser) parseAnd() (*KeywordAnd, error) {
+	and := &KeywordAnd{}
+	for {
+		token := p.peek()
+		if token == nil || token.kind == tokenClose {
+			return and, nil
+		}
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		and.Children = append(and.Children, node)
+	}
+}
+
+// parseOr スラッシュ区切りの条件をORとして評価する
+func (p *keywordParser) parseOr() (KeywordNode, error) {
+	node, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	token := p.peek()
+	if token == nil || token.kind != tokenOr {
+		return node, nil
+	}
+	or := &KeywordOr{Children: []KeywordNode{node}}
+	for token != nil && token.kind == tokenOr {
+		p.pos++
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		or.Children = append(or.Children, node)
+		token = p.peek()
+	}
+	return or, nil
+}
+
+func (p *keywordParser) parseUnary() (KeywordNode, error) {
+	token := p.peek()
+	if token == nil {
+		return nil, &KeywordError{Pos: p.length, Message: "missing keyword"}
+	}
+	switch token.kind {
+	case tokenNot:
+		p.pos++
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &KeywordNot{Child: node}, nil
+	case tokenOpen:
+		p.pos++
+		and, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.peek(); closing == nil {
+			return nil, &KeywordError{Pos: token.pos, Message: "unclosed parenthesis"}
+		}
+		p.pos++
+		if len(and.Children) == 0 {
+			return nil, &KeywordError{Pos: token.pos, Message: "empty parenthesis"}
+		}
+		if len(and.Children) == 1 {
+			return and.Children[0], nil
+		}
+		return and, nil
+	case tokenWord, tokenPhrase:
+		p.pos++
+		return &KeywordTerm{Field: token.field, Text: token.text}, nil
+	default:
+		return nil, &KeywordError{Pos: token.pos, Message: "missing keyword"}
+	}
+}
+
+// ParseKeywords キーワードを解析して構文木を作成する
+// 空白はAND、「/」はOR、先頭の「-」は除外、「"」で囲んだ語句は一つの語句、「()」はグループ、
+// 「brand:UNIQLO」のような項目名付きの値は項目の完全一致として評価する
+// エラーの位置は字句の位置と同じくNFKCで正規化した文字列の文字の位置とする
+func ParseKeywords(keywords string) (*KeywordAnd, error) {
+	normalized := norm.NFKC.String(keywords)
+	tokens, err := tokenizeKeywords(normalized)
+	if err != nil {
+		return nil, err
+	}
+	p := &keywordParser{tokens: tokens, length: len([]rune(normalized))}
+	and, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	if token := p.peek(); token != nil {
+		return nil, &KeywordError{Pos: token.pos, Message: "unexpected )"}
+	}
+	return and, nil
+}
@@ -0,0 +1,35 @@
+package domain
+
+import "testing"
+
+func TestParseKeywordsError(t *testing.T) {
+	tests := []struct {
+		keywords string
+		pos      int
+	}{
+		{`"シャツ`, 0},
+		{`シャツ ""`, 4},
+		{`(シャツ`, 0},
+		{`シャツ)`, 3},
+		{`()`, 0},
+		{`シャツ -`, 4},
+		{`シャツ/`, 4},
+		{`/シャツ`, 0},
+		{`brand:`, 0},
+		{`brand:"UNIQLO`, 6},
+		// 半角カナは正規化した後の文字の位置とする
+		{`ﾃﾞﾆﾑ/`, 4},
+		{`ﾃﾞﾆﾑ (`, 4},
+	}
+	for _, test := range tests {
+		_, err := ParseKeywords(test.keywords)
+		keywordError, ok := err.(*KeywordError)
+		if !ok {
+			t.Errorf("ParseKeywords(%q) error:%v", test.keywords, err)
+			continue
+		}
+		if keywordError.Pos != test.pos {
+			t.Errorf("ParseKeywords(%q) error position:%d <> %d", test.keywords, keywordError.Pos, test.pos)
+		}
+	}
+}
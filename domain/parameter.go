@@ -0,0 +1,51 @@
+package domain
+
+// Choices パラメータに指定できる値の一覧
+type Choices []string
+
+// Contains 指定できる値か
+func (choices Choices) Contains(value string) bool {
+	for _, choice := range choices {
+		if choice == value {
+			return true
+		}
+	}
+	return false
+}
+
+// 商品の並び順
+const (
+	OrderNew      = "new"
+	OrderMinPrice = "min-max"
+	OrderMaxPrice = "max-max"
+	OrderPopular  = "popular"
+	OrderTrending = "trending"
+)
+
+// おすすめ商品の選び方、strategyとfallbackで指定する
+const (
+	RecommendSameCategory = "same_category"
+	RecommendSimilar      = "similar"
+	RecommendCoViewed     = "co_viewed"
+	RecommendSameGender   = "same_gender"
+	RecommendPopular      = "popular"
+)
+
+// 分類の返却形式
+const (
+	ClassificationList = "list"
+	ClassificationTree = "tree"
+)
+
+var (
+	// SearchOrders 商品検索で指定できる並び順
+	SearchOrders = Choices{OrderNew, OrderMinPrice, OrderMaxPrice, OrderPopular, OrderTrending}
+	// DiversifyFields 同じ値の商品がページ内に偏らないようにできる項目
+	DiversifyFields = Choices{FacetBrand, FacetCategory}
+	// RecommendStrategies strategyで指定できるおすすめ商品の選び方
+	RecommendStrategies = Choices{RecommendSameCategory, RecommendSimilar, RecommendCoViewed}
+	// RecommendFallbacks fallbackで指定できる代わりの選び方
+	RecommendFallbacks = Choices{RecommendSameCategory, RecommendSameGender, RecommendPopular}
+	// ClassificationModes modeで指定できる分類の返却形式
+	ClassificationModes = Choices{ClassificationList, ClassificationTree}
+)
@@ -0,0 +1,47 @@
+package domain
+
+// ResultVersion 検索結果のJSONの形式の版、互換性のない変更をする場合に上げる
+const ResultVersion = 1
+
+// SearchItem 検索結果の商品
+type SearchItem struct {
+	*Item
+	Highlight map[string][]string `json:"highlight,omitempty"`
+	// FitSKU 体型を指定したおすすめで最も体型に合う在庫のあるSKU
+	FitSKU *SKU `json:"fit_sku,omitempty"`
+}
+
+// SearchResult struct
+type SearchResult struct {
+	Version int           `json:"version"`
+	Total   int64         `json:"total"`
+	Items   []*SearchItem `json:"items"`
+	Cursor  string        `json:"cursor,omitempty"`
+	Facets  *Facets       `json:"facets,omitempty"`
+	// Tier おすすめ商品を選んだ方法、元の方法で見つからない場合は代わりに使った方法になる
+	Tier string `json:"tier,omitempty"`
+}
+
+// Classification struct
+type Classification struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Gender   string `json:"gender,omitempty"`
+	SortNo   int    `json:"sort_no"`
+	ParentID string `json:"parent_id,omitempty"`
+}
+
+// ClassificationNode 階層構造の分類、Countは子の分類の商品を含めた件数
+type ClassificationNode struct {
+	*Classification
+	Count    int64                 `json:"count"`
+	Children []*ClassificationNode `json:"children"`
+}
+
+// ClassificationResult struct
+type ClassificationResult struct {
+	Version int                   `json:"version"`
+	Total   int64                 `json:"total"`
+	Hits    []*Classification     `json:"hits"`
+	Tree    []*ClassificationNode `json:"tree,omitempty"`
+}
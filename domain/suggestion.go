@@ -0,0 +1,15 @@
+package domain
+
+// Suggestion struct
+type Suggestion struct {
+	Text  string `json:"text"`
+	Count int64  `json:"count"`
+}
+
+// Suggestions struct
+type Suggestions struct {
+	Version    int          `json:"version"`
+	Keywords   []Suggestion `json:"keywords"`
+	Brands     []Suggestion `json:"brands"`
+	Categories []Suggestion `json:"categories"`
+}
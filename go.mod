@@ -3,11 +3,13 @@ module github.com/akaishi-sandbox/sam-go
 go 1.13
 
 require (
-	github.com/aws/aws-lambda-go v1.14.0
+	github.com/aws/aws-lambda-go v1.19.1
 	github.com/aws/aws-sdk-go v1.29.8
 	github.com/awslabs/aws-lambda-go-api-proxy v0.6.0
 	github.com/getsentry/sentry-go v0.5.1
 	github.com/labstack/echo v3.3.10+incompatible
 	github.com/olivere/elastic v6.2.27+incompatible
 	github.com/olivere/elastic/v7 v7.0.11
+	golang.org/x/text v0.3.2
+	gopkg.in/yaml.v2 v2.2.4
 )
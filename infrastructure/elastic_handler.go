@@ -2,9 +2,13 @@ package infrastructure
 
 import (
 	"context"
+	"errors"
 	"log"
+	"net"
+	"net/http"
 	"os"
 
+	"github.com/akaishi-sandbox/sam-go/domain"
 	"github.com/aws/aws-sdk-go/aws/session"
 	aws "github.com/olivere/elastic/aws/v4"
 	elastic "github.com/olivere/elastic/v7"
@@ -12,41 +16,176 @@ import (
 
 // ElasticHandler struct
 type ElasticHandler struct {
-	Client  *elastic.Client
-	Context context.Context
+	Client *elastic.Client
 }
 
 // ElasticQuery struct
 type ElasticQuery struct {
-	Index    string
-	Query    *elastic.BoolQuery
-	SortInfo elastic.SortInfo
-	From     int
-	Size     int
+	Index        string
+	Query        elastic.Query
+	PostFilter   elastic.Query
+	Aggregations map[string]elastic.Aggregation
+	Highlight    *elastic.Highlight
+	Sort         []elastic.Sorter
+	SearchAfter  []interface{}
+	// Collapse 同じ値のドキュメントを1件にまとめる
+	Collapse *elastic.CollapseBuilder
+	// TrackTotalHits 10000件を超える場合も正確な件数を返却する
+	TrackTotalHits bool
+	From           int
+	Size           int
 }
 
-// Search function
-func (handler *ElasticHandler) Search(eq *ElasticQuery) (*elastic.SearchResult, error) {
-	return handler.Client.Search().
-		Index(eq.Index).
+func (eq *ElasticQuery) searchSource() *elastic.SearchSource {
+	source := elastic.NewSearchSource().
 		Query(eq.Query).
-		SortWithInfo(eq.SortInfo).
+		SortBy(eq.Sort...).
 		From(eq.From).
-		Size(eq.Size). // take documents from-(size-from)
-		Pretty(true).  // pretty print request and response JSON
-		Do(handler.Context)
+		Size(eq.Size) // take documents from-(size-from)
+	if len(eq.SearchAfter) > 0 {
+		source = source.SearchAfter(eq.SearchAfter...)
+	}
+	if eq.Collapse != nil {
+		source = source.Collapse(eq.Collapse)
+	}
+	if eq.PostFilter != nil {
+		source = source.PostFilter(eq.PostFilter)
+	}
+	for name, aggregation := range eq.Aggregations {
+		source = source.Aggregation(name, aggregation)
+	}
+	if eq.Highlight != nil {
+		source = source.Highlight(eq.Highlight)
+	}
+	if eq.TrackTotalHits {
+		source = source.TrackTotalHits(true)
+	}
+	return source
+}
+
+// elasticsearchService エラーに含める依存先の名前
+const elasticsearchService = "elasticsearch"
+
+// elasticError Elasticsearchのエラーを原因毎のドメインのエラーに変換する、変換できないものはそのまま返却する
+func elasticError(err error) error {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return &domain.CanceledError{Service: elasticsearchService, Err: err}
+	case errors.Is(err, context.DeadlineExceeded) || elastic.IsTimeout(err) || (errors.As(err, &netErr) && netErr.Timeout()):
+		return &domain.TimeoutError{Service: elasticsearchService, Err: err}
+	case elastic.IsConnErr(err) || elastic.IsStatusCode(err, http.StatusTooManyRequests):
+		return &domain.UnavailableError{Service: elasticsearchService, Err: err}
+	}
+	var elasticErr *elastic.Error
+	if !errors.As(err, &elasticErr) || elasticErr.Status >= http.StatusInternalServerError {
+		// ステータスのないエラーは接続の失敗による
+		return &domain.UnavailableError{Service: elasticsearchService, Err: err}
+	}
+	// 検索の条件が受け付けられない場合はパラメータの誤りとする、理由には内部の情報が含まれるため返却しない
+	if elasticErr.Status == http.StatusBadRequest {
+		return domain.NewValidationError("query", "is rejected by "+elasticsearchService)
+	}
+	return err
+}
+
+// bulkItemError 一括処理の個別のエラーを変換する
+func bulkItemError(failed *elastic.BulkResponseItem) error {
+	if failed.Status == http.StatusConflict {
+		return &domain.ConflictError{Resource: failed.Index, ID: failed.Id}
+	}
+	return elasticError(&elastic.Error{Status: failed.Status, Details: failed.Error})
+}
+
+// Search function
+func (handler *ElasticHandler) Search(ctx context.Context, eq *ElasticQuery) (*elastic.SearchResult, error) {
+	searchResult, err := handler.Client.Search().
+		Index(eq.Index).
+		SearchSource(eq.searchSource()).
+		Pretty(true). // pretty print request and response JSON
+		Do(ctx)
+	if err != nil {
+		return nil, elasticError(err)
+	}
+	return searchResult, nil
+}
+
+// MultiSearch function
+func (handler *ElasticHandler) MultiSearch(ctx context.Context, eqs ...*ElasticQuery) (*elastic.MultiSearchResult, error) {
+	search := handler.Client.MultiSearch()
+	for _, eq := range eqs {
+		search = search.Add(elastic.NewSearchRequest().Index(eq.Index).SearchSource(eq.searchSource()))
+	}
+	searchResult, err := search.Do(ctx)
+	if err != nil {
+		return nil, elasticError(err)
+	}
+	// 個別の検索のエラーはレスポンスに含まれるためエラーとして返却する
+	for _, response := range searchResult.Responses {
+		if response.Error != nil {
+			return nil, elasticError(&elastic.Error{Status: response.Status, Details: response.Error})
+		}
+	}
+	return searchResult, nil
+}
+
+// ElasticUpdate struct
+type ElasticUpdate struct {
+	Hit    *elastic.SearchHit
+	Script *elastic.Script
+}
+
+// updateRetryOnConflict 同じドキュメントが同時に更新された場合に再試行する回数
+const updateRetryOnConflict = 5
+
+// BulkUpdate function
+func (handler *ElasticHandler) BulkUpdate(ctx context.Context, updates []*ElasticUpdate) (*elastic.BulkResponse, error) {
+	bulk := handler.Client.Bulk()
+	for _, update := range updates {
+		bulk = bulk.Add(elastic.NewBulkUpdateRequest().
+			Index(update.Hit.Index).
+			Id(update.Hit.Id).
+			Script(update.Script).
+			RetryOnConflict(updateRetryOnConflict))
+	}
+	bulkResponse, err := bulk.Do(ctx)
+	if err != nil {
+		return nil, elasticError(err)
+	}
+	// 個別の更新のエラーはレスポンスに含まれるため最初のエラーを返却する
+	for _, failed := range bulkResponse.Failed() {
+		return nil, bulkItemError(failed)
+	}
+	return bulkResponse, nil
 }
 
-// Update function
-func (handler *ElasticHandler) Update(hit *elastic.SearchHit, update interface{}) (*elastic.UpdateResponse, error) {
-	return handler.Client.Update().Index(hit.Index).Id(hit.Id).
-		// Script(elastic.NewScript("ctx._source.access_counter = params.access_counter").Param("access_counter", numberOfAccess)).
-		// Script(elastic.NewScript("ctx._source.last_accessed_at = params.last_accessed_at").Param("last_accessed_at", lastAccessedAt)).
-		Do(handler.Context)
+// ElasticDocument struct
+type ElasticDocument struct {
+	Index string
+	Body  interface{}
+}
+
+// BulkIndex function
+func (handler *ElasticHandler) BulkIndex(ctx context.Context, documents []*ElasticDocument) (*elastic.BulkResponse, error) {
+	bulk := handler.Client.Bulk()
+	for _, document := range documents {
+		bulk = bulk.Add(elastic.NewBulkIndexRequest().
+			Index(document.Index).
+			Doc(document.Body))
+	}
+	bulkResponse, err := bulk.Do(ctx)
+	if err != nil {
+		return nil, elasticError(err)
+	}
+	// 個別の登録のエラーはレスポンスに含まれるため最初のエラーを返却する
+	for _, failed := range bulkResponse.Failed() {
+		return nil, bulkItemError(failed)
+	}
+	return bulkResponse, nil
 }
 
 // NewElasticHandler instance
-func NewElasticHandler(ctx context.Context, elasticsearchAddress string) (*ElasticHandler, error) {
+func NewElasticHandler(elasticsearchAddress string) (*ElasticHandler, error) {
 	sess, err := session.NewSession()
 	if err != nil {
 		return nil, err
@@ -66,7 +205,6 @@ func NewElasticHandler(ctx context.Context, elasticsearchAddress string) (*Elast
 	}
 
 	return &ElasticHandler{
-		Client:  es,
-		Context: ctx,
+		Client: es,
 	}, nil
 }
@@ -0,0 +1,34 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/akaishi-sandbox/sam-go/domain"
+	elastic "github.com/olivere/elastic/v7"
+)
+
+func TestElasticError(t *testing.T) {
+	var canceledErr *domain.CanceledError
+	if err := elasticError(fmt.Errorf("search: %w", context.Canceled)); !errors.As(err, &canceledErr) {
+		t.Errorf("canceled error:%T %v", err, err)
+	}
+	var timeoutErr *domain.TimeoutError
+	if err := elasticError(context.DeadlineExceeded); !errors.As(err, &timeoutErr) {
+		t.Errorf("timeout error:%T %v", err, err)
+	}
+	var validationErr *domain.ValidationError
+	if err := elasticError(&elastic.Error{Status: http.StatusBadRequest, Details: &elastic.ErrorDetails{Reason: "Result window is too large"}}); !errors.As(err, &validationErr) {
+		t.Errorf("bad request error:%T %v", err, err)
+	}
+	var unavailableErr *domain.UnavailableError
+	if err := elasticError(&elastic.Error{Status: http.StatusServiceUnavailable}); !errors.As(err, &unavailableErr) {
+		t.Errorf("unavailable error:%T %v", err, err)
+	}
+	if err := elasticError(&elastic.Error{Status: http.StatusNotFound}); errors.As(err, &validationErr) || errors.As(err, &unavailableErr) {
+		t.Errorf("not found error:%T %v", err, err)
+	}
+}
@@ -0,0 +1,42 @@
+package infrastructure
+
+import (
+	"context"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// RequestTimeoutOptions struct
+type RequestTimeoutOptions struct {
+	// Margin Lambdaの実行期限までにレスポンスの返却と溜めたアクセスの書き込みを行うために残す時間
+	Margin time.Duration
+	// Timeout 実行期限がない場合や期限までの時間が長い場合のリクエストの時間の上限、0の場合は上限を設けない
+	Timeout time.Duration
+}
+
+// RequestTimeout リクエストのcontextにLambdaの残りの実行時間から求めた期限を設定する
+// 期限を過ぎたElasticsearchへの検索は中断される
+func RequestTimeout(options RequestTimeoutOptions) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx, cancel := requestContext(c.Request().Context(), options)
+			defer cancel()
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+func requestContext(parent context.Context, options RequestTimeoutOptions) (context.Context, context.CancelFunc) {
+	timeout := options.Timeout
+	if deadline, ok := parent.Deadline(); ok {
+		remaining := time.Until(deadline) - options.Margin
+		if timeout <= 0 || remaining < timeout {
+			timeout = remaining
+		}
+	} else if timeout <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, timeout)
+}
@@ -0,0 +1,34 @@
+package infrastructure
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRequestContext(t *testing.T) {
+	testCase := func(parent context.Context, options RequestTimeoutOptions, expected time.Duration, hasDeadline bool) {
+		t.Helper()
+		ctx, cancel := requestContext(parent, options)
+		defer cancel()
+		deadline, ok := ctx.Deadline()
+		if ok != hasDeadline {
+			t.Fatalf("deadline error:%v %v", deadline, ok)
+		}
+		if !ok {
+			return
+		}
+		// 期限の計算からの経過時間の誤差を許容する
+		if remaining := time.Until(deadline); remaining > expected || remaining < expected-time.Second {
+			t.Errorf("timeout error:%v expected:%v", remaining, expected)
+		}
+	}
+	lambdaContext, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	testCase(context.Background(), RequestTimeoutOptions{}, 0, false)
+	testCase(context.Background(), RequestTimeoutOptions{Margin: time.Second, Timeout: 30 * time.Second}, 30*time.Second, true)
+	testCase(lambdaContext, RequestTimeoutOptions{Margin: time.Second, Timeout: 30 * time.Second}, 9*time.Second, true)
+	testCase(lambdaContext, RequestTimeoutOptions{Margin: time.Second, Timeout: 5 * time.Second}, 5*time.Second, true)
+	testCase(lambdaContext, RequestTimeoutOptions{Margin: time.Second}, 9*time.Second, true)
+}
@@ -2,14 +2,16 @@ package infrastructure
 
 import (
 	"context"
-	"net/http"
 	"time"
 
 	"github.com/getsentry/sentry-go"
 	"github.com/labstack/echo"
 )
 
-const valuesKey = "sentry"
+const (
+	valuesKey    = "sentry"
+	recoveredKey = "sentry.recovered"
+)
 
 type handler struct {
 	repanic         bool
@@ -59,13 +61,15 @@ func (h *handler) handle(next echo.HandlerFunc) echo.HandlerFunc {
 		hub := sentry.CurrentHub().Clone()
 		hub.Scope().SetRequest(sentry.Request{}.FromHTTPRequest(ctx.Request()))
 		ctx.Set(valuesKey, hub)
-		defer h.recoverWithSentry(hub, ctx.Request())
+		defer h.recoverWithSentry(hub, ctx)
 		return next(ctx)
 	}
 }
 
-func (h *handler) recoverWithSentry(hub *sentry.Hub, r *http.Request) {
+func (h *handler) recoverWithSentry(hub *sentry.Hub, ctx echo.Context) {
 	if err := recover(); err != nil {
+		r := ctx.Request()
+		ctx.Set(recoveredKey, true)
 		eventID := hub.RecoverWithContext(
 			context.WithValue(r.Context(), sentry.RequestContextKey, r),
 			err,
@@ -79,6 +83,12 @@ func (h *handler) recoverWithSentry(hub *sentry.Hub, r *http.Request) {
 	}
 }
 
+// IsRecovered reports whether a panic of the request has already been sent to Sentry.
+func IsRecovered(ctx echo.Context) bool {
+	recovered, _ := ctx.Get(recoveredKey).(bool)
+	return recovered
+}
+
 // GetHubFromContext retrieves attached *sentry.Hub instance from echo.Context.
 func GetHubFromContext(ctx echo.Context) *sentry.Hub {
 	if hub, ok := ctx.Get(valuesKey).(*sentry.Hub); ok {
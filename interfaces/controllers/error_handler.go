@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/akaishi-sandbox/sam-go/domain"
+	"github.com/akaishi-sandbox/sam-go/infrastructure"
+	"github.com/labstack/echo"
+)
+
+// エラーの種類を表すコード、クライアントはmessageではなくcodeで判定する
+const (
+	ErrorCodeInvalidParameter    = "invalid_parameter"
+	ErrorCodeNotFound            = "not_found"
+	ErrorCodeConflict            = "conflict"
+	ErrorCodeUpstreamUnavailable = "upstream_unavailable"
+	ErrorCodeTimeout             = "timeout"
+	ErrorCodeCanceled            = "canceled"
+	ErrorCodeInternal            = "internal_error"
+)
+
+// statusClientClosedRequest クライアントが切断して処理を中断した場合のステータス、nginxと同じ値を使う
+const statusClientClosedRequest = 499
+
+// ErrorResponse エラー時に返却するJSON
+type ErrorResponse struct {
+	Code      string              `json:"code"`
+	Message   string              `json:"message"`
+	Fields    []domain.FieldError `json:"fields,omitempty"`
+	RequestID string              `json:"request_id,omitempty"`
+}
+
+// newErrorResponse エラーの種類からステータスとレスポンスを作成する
+func newErrorResponse(err error) (int, *ErrorResponse) {
+	var validationErr *domain.ValidationError
+	var notFoundErr *domain.NotFoundError
+	var conflictErr *domain.ConflictError
+	var unavailableErr *domain.UnavailableError
+	var timeoutErr *domain.TimeoutError
+	var canceledErr *domain.CanceledError
+	var httpErr *echo.HTTPError
+	switch {
+	case errors.As(err, &validationErr):
+		return http.StatusBadRequest, &ErrorResponse{Code: ErrorCodeInvalidParameter, Message: validationErr.Message, Fields: validationErr.Fields}
+	case errors.As(err, &notFoundErr):
+		return http.StatusNotFound, &ErrorResponse{Code: ErrorCodeNotFound, Message: notFoundErr.Error()}
+	case errors.As(err, &conflictErr):
+		return http.StatusConflict, &ErrorResponse{Code: ErrorCodeConflict, Message: conflictErr.Error()}
+	case errors.As(err, &canceledErr):
+		return statusClientClosedRequest, &ErrorResponse{Code: ErrorCodeCanceled, Message: canceledErr.Service + " canceled"}
+	case errors.As(err, &timeoutErr):
+		return http.StatusGatewayTimeout, &ErrorResponse{Code: ErrorCodeTimeout, Message: timeoutErr.Service + " timeout"}
+	case errors.As(err, &unavailableErr):
+		return http.StatusServiceUnavailable, &ErrorResponse{Code: ErrorCodeUpstreamUnavailable, Message: unavailableErr.Service + " unavailable"}
+	case errors.As(err, &httpErr):
+		// ルーティングなどechoが返すエラー
+		code := strings.ToLower(strings.ReplaceAll(http.StatusText(httpErr.Code), " ", "_"))
+		return httpErr.Code, &ErrorResponse{Code: code, Message: http.StatusText(httpErr.Code)}
+	default:
+		// 内部のエラーの詳細はクライアントに返却しない
+		return http.StatusInternalServerError, &ErrorResponse{Code: ErrorCodeInternal, Message: http.StatusText(http.StatusInternalServerError)}
+	}
+}
+
+// HTTPErrorHandler ハンドラが返却したエラーをJSONで返却する、5xxのエラーのみSentryに送信する
+// panicはSentryのミドルウェアで送信済みのため送信しない
+func HTTPErrorHandler(err error, c echo.Context) {
+	status, response := newErrorResponse(err)
+	response.RequestID = c.Response().Header().Get(echo.HeaderXRequestID)
+	if status >= http.StatusInternalServerError {
+		if hub := infrastructure.GetHubFromContext(c); hub != nil && !infrastructure.IsRecovered(c) {
+			hub.CaptureException(err)
+		}
+		c.Logger().Error(err)
+	}
+	if c.Response().Committed {
+		return
+	}
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(status)
+	} else {
+		err = c.JSON(status, response)
+	}
+	if err != nil {
+		c.Logger().Error(err)
+	}
+}
@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/akaishi-sandbox/sam-go/domain"
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+)
+
+func TestHTTPErrorHandler(t *testing.T) {
+	testCase := func(err error, status int, code string) {
+		t.Helper()
+		e := newTestEcho()
+		e.Use(middleware.RequestID())
+		e.GET("/error", func(c echo.Context) error {
+			return err
+		})
+		req := httptest.NewRequest(http.MethodGet, "/error", nil)
+		req.Header.Set(echo.HeaderXRequestID, "R001")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		if rec.Code != status {
+			t.Errorf("status error:%v %d %s", err, rec.Code, rec.Body.String())
+		}
+		var response ErrorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("response error:%v", err)
+		}
+		if response.Code != code || len(response.Message) == 0 || response.RequestID != "R001" {
+			t.Errorf("error response error:%v %s", err, rec.Body.String())
+		}
+	}
+	testCase(domain.NewValidationError("limit", "is invalid"), http.StatusBadRequest, ErrorCodeInvalidParameter)
+	testCase(&domain.NotFoundError{Resource: "brand", ID: "1"}, http.StatusNotFound, ErrorCodeNotFound)
+	testCase(&domain.ConflictError{Resource: "items", ID: "A001"}, http.StatusConflict, ErrorCodeConflict)
+	testCase(&domain.UnavailableError{Service: "elasticsearch", Err: errors.New("connection refused")}, http.StatusServiceUnavailable, ErrorCodeUpstreamUnavailable)
+	testCase(&domain.TimeoutError{Service: "elasticsearch", Err: errors.New("deadline exceeded")}, http.StatusGatewayTimeout, ErrorCodeTimeout)
+	testCase(&domain.CanceledError{Service: "elasticsearch", Err: errors.New("context canceled")}, 499, ErrorCodeCanceled)
+	testCase(errors.New("invalid suggest response"), http.StatusInternalServerError, ErrorCodeInternal)
+	testCase(echo.ErrMethodNotAllowed, http.StatusMethodNotAllowed, "method_not_allowed")
+}
+
+func TestHTTPErrorHandlerHidesInternalError(t *testing.T) {
+	status, response := newErrorResponse(errors.New("elastic: Error 400 (Bad Request)"))
+	if status != http.StatusInternalServerError || response.Message != http.StatusText(http.StatusInternalServerError) {
+		t.Errorf("internal error response:%d %+v", status, response)
+	}
+}
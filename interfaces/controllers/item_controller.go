@@ -12,22 +12,33 @@ import (
 // ItemController struct
 type ItemController struct {
 	Interactor usecase.ItemInteractor
+	// DefaultLimit, ClassificationLimit limitを指定しない場合の件数、offsetがElasticsearchの上限を超えないか検証するために使う
+	DefaultLimit        int
+	ClassificationLimit int
 }
 
-// NewItemController instance
-func NewItemController(elasticHandler *infrastructure.ElasticHandler) *ItemController {
+// NewItemController instance, accessEventBufferSizeは溜めたアクセスをまとめて書き込む件数
+func NewItemController(elasticHandler *infrastructure.ElasticHandler, searchConfig database.SearchConfig, accessEventBufferSize int) *ItemController {
+	itemRepository := &database.ItemRepository{
+		ElasticHandler: elasticHandler,
+		Config:         searchConfig,
+	}
 	return &ItemController{
 		Interactor: usecase.ItemInteractor{
-			ItemRepository: &database.ItemRepository{
-				ElasticHandler: elasticHandler,
-			},
+			ItemRepository:  itemRepository,
+			AccessEventSink: usecase.NewBufferedAccessEventSink(itemRepository, accessEventBufferSize),
 		},
+		DefaultLimit:        searchConfig.DefaultLimit,
+		ClassificationLimit: searchConfig.ClassificationLimit,
 	}
 }
 
 func (controller *ItemController) queryStringParameters(c echo.Context) map[string]string {
-	parameters := make(map[string]string, len(c.ParamNames()))
+	parameters := make(map[string]string, len(c.QueryParams())+len(c.ParamNames()))
 
+	for name := range c.QueryParams() {
+		parameters[name] = c.QueryParam(name)
+	}
 	for _, name := range c.ParamNames() {
 		parameters[name] = c.Param(name)
 	}
@@ -37,9 +48,12 @@ func (controller *ItemController) queryStringParameters(c echo.Context) map[stri
 
 // Search function
 func (controller *ItemController) Search(c echo.Context) (err error) {
-	searchResult, err := controller.Interactor.Search(controller.queryStringParameters(c))
+	request, err := usecase.NewSearchRequest(controller.queryStringParameters(c), controller.DefaultLimit)
+	if err != nil {
+		return
+	}
+	searchResult, err := controller.Interactor.Search(c.Request().Context(), request)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
 		return
 	}
 	c.JSON(http.StatusOK, searchResult)
@@ -48,9 +62,12 @@ func (controller *ItemController) Search(c echo.Context) (err error) {
 
 // Recommend function
 func (controller *ItemController) Recommend(c echo.Context) (err error) {
-	searchResult, err := controller.Interactor.Recommend(controller.queryStringParameters(c))
+	request, err := usecase.NewRecommendRequest(controller.queryStringParameters(c), controller.DefaultLimit)
+	if err != nil {
+		return
+	}
+	searchResult, err := controller.Interactor.Recommend(c.Request().Context(), request)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
 		return
 	}
 	c.JSON(http.StatusOK, searchResult)
@@ -59,22 +76,57 @@ func (controller *ItemController) Recommend(c echo.Context) (err error) {
 
 // Classification function
 func (controller *ItemController) Classification(c echo.Context) (err error) {
-	searchResult, err := controller.Interactor.Classification(controller.queryStringParameters(c))
+	request, err := usecase.NewClassificationRequest(controller.queryStringParameters(c), controller.ClassificationLimit)
+	if err != nil {
+		return
+	}
+	searchResult, err := controller.Interactor.Classification(c.Request().Context(), request)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
 		return
 	}
 	c.JSON(http.StatusOK, searchResult)
 	return
 }
 
+// Suggest function
+func (controller *ItemController) Suggest(c echo.Context) (err error) {
+	request, err := usecase.NewSuggestRequest(controller.queryStringParameters(c))
+	if err != nil {
+		return
+	}
+	suggestions, err := controller.Interactor.Suggest(c.Request().Context(), request)
+	if err != nil {
+		return
+	}
+	c.JSON(http.StatusOK, suggestions)
+	return
+}
+
+// Brand function
+func (controller *ItemController) Brand(c echo.Context) (err error) {
+	request, err := usecase.NewBrandRequest(controller.queryStringParameters(c))
+	if err != nil {
+		return
+	}
+	brandDetail, err := controller.Interactor.Brand(c.Request().Context(), request)
+	if err != nil {
+		return
+	}
+	c.JSON(http.StatusOK, brandDetail)
+	return
+}
+
 // Access function
 func (controller *ItemController) Access(c echo.Context) (err error) {
-	searchResult, err := controller.Interactor.AccessInfo(controller.queryStringParameters(c))
+	request, err := usecase.NewAccessRequest(controller.queryStringParameters(c))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
 		return
 	}
-	c.JSON(http.StatusOK, searchResult)
+	accessEvent, err := controller.Interactor.AccessInfo(c.Request().Context(), request)
+	if err != nil {
+		return
+	}
+	// アクセスは溜めておき、Lambdaの呼び出しの終了前にまとめて書き込む
+	c.JSON(http.StatusAccepted, accessEvent)
 	return
 }
@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/akaishi-sandbox/sam-go/domain"
+	"github.com/akaishi-sandbox/sam-go/interfaces/database/databasetest"
+	"github.com/akaishi-sandbox/sam-go/usecase"
+	"github.com/labstack/echo"
+)
+
+func newTestItemController() *ItemController {
+	return &ItemController{
+		Interactor: usecase.ItemInteractor{
+			ItemRepository: databasetest.NewItemRepository(),
+		},
+		DefaultLimit:        databasetest.SearchConfig.DefaultLimit,
+		ClassificationLimit: databasetest.SearchConfig.ClassificationLimit,
+	}
+}
+
+func newTestEcho() *echo.Echo {
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	return e
+}
+
+func TestItemControllerSearch(t *testing.T) {
+	controller := newTestItemController()
+	e := newTestEcho()
+	e.GET("/search-items", controller.Search)
+
+	query := url.Values{}
+	query.Set("keywords", "シャツ -デニム")
+	req := httptest.NewRequest(http.MethodGet, "/search-items?"+query.Encode(), nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status error:%d %s", rec.Code, rec.Body.String())
+	}
+	var result domain.SearchResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("response error:%v", err)
+	}
+	if result.Total != 2 || result.Items[1].ItemID != "A001" {
+		t.Errorf("search result error:%s", rec.Body.String())
+	}
+}
+
+func TestItemControllerSearchBadRequest(t *testing.T) {
+	controller := newTestItemController()
+	e := newTestEcho()
+	e.GET("/search-items", controller.Search)
+
+	query := url.Values{}
+	query.Set("keywords", `"シャツ`)
+	req := httptest.NewRequest(http.MethodGet, "/search-items?"+query.Encode(), nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status error:%d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestItemControllerSearchInvalidParameters(t *testing.T) {
+	controller := newTestItemController()
+	e := newTestEcho()
+	e.GET("/search-items", controller.Search)
+
+	req := httptest.NewRequest(http.MethodGet, "/search-items?order=cheap&limit=1000", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status error:%d %s", rec.Code, rec.Body.String())
+	}
+	var response ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("response error:%v", err)
+	}
+	if response.Code != ErrorCodeInvalidParameter || len(response.Fields) != 2 || response.Fields[0].Field != "order" || response.Fields[1].Field != "limit" {
+		t.Errorf("validation error:%s", rec.Body.String())
+	}
+}
+
+func TestItemControllerRecommendNotFound(t *testing.T) {
+	controller := newTestItemController()
+	e := newTestEcho()
+	e.GET("/recommend-items", controller.Recommend)
+
+	req := httptest.NewRequest(http.MethodGet, "/recommend-items?item_id=UNKNOWN", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status error:%d %s", rec.Code, rec.Body.String())
+	}
+	var result domain.SearchResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("response error:%v", err)
+	}
+	if result.Tier != "popular" || result.Total != 4 {
+		t.Errorf("fallback result error:%s", rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/recommend-items?item_id=UNKNOWN&strict=1", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status error:%d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestItemControllerBrand(t *testing.T) {
+	controller := newTestItemController()
+	e := newTestEcho()
+	e.GET("/brands/:id", controller.Brand)
+
+	req := httptest.NewRequest(http.MethodGet, "/brands/2", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status error:%d %s", rec.Code, rec.Body.String())
+	}
+	var detail domain.BrandDetail
+	if err := json.Unmarshal(rec.Body.Bytes(), &detail); err != nil {
+		t.Fatalf("response error:%v", err)
+	}
+	if detail.Version != domain.ResultVersion || detail.Brand.Title != "UNIQLO" || detail.ItemCount != 2 || *detail.MinPrice != 2990 {
+		t.Errorf("brand detail error:%s", rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/brands/9", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status error:%d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestItemControllerSuggestBadRequest(t *testing.T) {
+	controller := newTestItemController()
+	e := newTestEcho()
+	e.GET("/suggest", controller.Suggest)
+
+	req := httptest.NewRequest(http.MethodGet, "/suggest?keywords=uni&limit=abc", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status error:%d %s", rec.Code, rec.Body.String())
+	}
+	var response ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("response error:%v", err)
+	}
+	if response.Code != ErrorCodeInvalidParameter || len(response.Fields) != 1 || response.Fields[0].Field != "limit" {
+		t.Errorf("error response:%s", rec.Body.String())
+	}
+}
+
+func TestItemControllerAccess(t *testing.T) {
+	controller := newTestItemController()
+	controller.Interactor.AccessEventSink = usecase.NewBufferedAccessEventSink(controller.Interactor.ItemRepository, 100)
+	e := newTestEcho()
+	e.GET("/access-info", controller.Access)
+
+	req := httptest.NewRequest(http.MethodGet, "/access-info?item_id=A001", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status error:%d %s", rec.Code, rec.Body.String())
+	}
+	var event domain.AccessEvent
+	if err := json.Unmarshal(rec.Body.Bytes(), &event); err != nil {
+		t.Fatalf("response error:%v", err)
+	}
+	if event.ItemID != "A001" || event.Count != 1 || event.SessionID != "" {
+		t.Errorf("access event error:%s", rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/access-info?item_id=A001&session_id=S001", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if err := json.Unmarshal(rec.Body.Bytes(), &event); err != nil {
+		t.Fatalf("response error:%v", err)
+	}
+	if event.SessionID != "S001" {
+		t.Errorf("access event session error:%s", rec.Body.String())
+	}
+}
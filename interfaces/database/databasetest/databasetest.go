@@ -0,0 +1,49 @@
+// Package databasetest 各層のテストで共通に使うメモリ上の商品と分類
+package databasetest
+
+import (
+	"time"
+
+	"github.com/akaishi-sandbox/sam-go/domain"
+	"github.com/akaishi-sandbox/sam-go/interfaces/database"
+)
+
+// SearchConfig テストで使う検索の設定、起動時の既定値と同じ値にしている
+var SearchConfig = database.SearchConfig{
+	Indices: database.Indices{
+		Items:        "items",
+		Categories:   "categories",
+		Brands:       "brands",
+		AccessEvents: "item_access_events",
+	},
+	DefaultLimit:        36,
+	ClassificationLimit: 100,
+	PopularityHalfLife:  30 * 24 * time.Hour,
+	TrendingHalfLife:    3 * 24 * time.Hour,
+	RecommendFallbacks:  []string{"same_gender", "popular"},
+	ClassificationSources: map[string]database.ClassificationSource{
+		"categories": {Filters: []string{"gender", "title", "parent_id"}},
+		"brands":     {Filters: []string{"gender", "title"}},
+	},
+}
+
+// NewItemRepository A001からA004の商品とブランド、カテゴリを持つメモリ上のリポジトリを作成する
+// 呼び出し毎に作成するため、テストで商品や分類を書き換えてもよい
+func NewItemRepository() *database.MemoryItemRepository {
+	updatedAt := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	return database.NewMemoryItemRepository([]*domain.Item{
+		{ItemID: "A001", Title: "オックスフォードシャツ", Brand: "UNIQLO", Gender: "MEN", Category: "シャツ", LowestPrice: 2990, DiscountFlag: 1, UpdatedAt: updatedAt, SKUs: []domain.SKU{{Size: "M", Bmi: 22, Stock: 3}}},
+		{ItemID: "A002", Title: "デニムシャツ", Brand: "UNIQLO", Gender: "MEN", Category: "シャツ", LowestPrice: 3990, UpdatedAt: updatedAt.Add(time.Hour), SKUs: []domain.SKU{{Size: "L", Bmi: 25, Stock: 0}}},
+		{ItemID: "A003", Title: "スリムパンツ", Brand: "GU", Gender: "MEN", Category: "パンツ", LowestPrice: 1990, UpdatedAt: updatedAt.Add(2 * time.Hour), SKUs: []domain.SKU{{Size: "S", Bmi: 19, Stock: 1}}},
+		{ItemID: "A004", Title: "ブラウス", Brand: "GU", Gender: "WOMEN", Category: "シャツ", LowestPrice: 1490, UpdatedAt: updatedAt.Add(3 * time.Hour)},
+	}, map[string][]*domain.Classification{
+		"brands": {
+			{ID: "2", Title: "UNIQLO", SortNo: 2},
+			{ID: "1", Title: "GU", SortNo: 1},
+		},
+		"categories": {
+			{ID: "1", Title: "シャツ", Gender: "MEN", SortNo: 1},
+			{ID: "2", Title: "パンツ", Gender: "MEN", SortNo: 2},
+		},
+	}, SearchConfig)
+}
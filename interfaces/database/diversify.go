@@ -0,0 +1,150 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/akaishi-sandbox/sam-go/domain"
+)
+
+const (
+	// defaultDiversifyLimit ページ内の同じ値の商品の件数の既定の上限
+	defaultDiversifyLimit = 3
+	// diversifyWindow 偏りをなくす場合や元の商品が複数の場合に並べ替える候補の件数
+	// ページ毎に候補が変わって重複や欠落が起きないよう固定の件数とし、この範囲を超えるページは取得できない
+	diversifyWindow = 360
+)
+
+// diversifyCondition 同じ値の商品をページ内でlimit件までとする条件
+type diversifyCondition struct {
+	field string
+	limit int
+}
+
+// parseDiversify diversify, diversify_limitを解析する、指定されていない場合はnilを返却する
+func parseDiversify(q map[string]string, config SearchConfig) (*diversifyCondition, error) {
+	field, ok := q["diversify"]
+	if !ok || len(field) == 0 {
+		return nil, nil
+	}
+	if !domain.DiversifyFields.Contains(field) {
+		return nil, domain.NewValidationError("diversify", "is not supported")
+	}
+	if cursor, ok := q["cursor"]; ok && len(cursor) > 0 {
+		return nil, domain.NewValidationError("diversify", "can not be used with cursor")
+	}
+	if from, size := parsePaging(q, config.DefaultLimit); from+size > diversifyWindow {
+		return nil, domain.NewValidationError("offset", fmt.Sprintf("plus limit must be at most %d with diversify", diversifyWindow))
+	}
+	condition := &diversifyCondition{field: field, limit: defaultDiversifyLimit}
+	if limit, ok := q["diversify_limit"]; ok {
+		if v, err := strconv.Atoi(limit); err == nil && v > 0 {
+			condition.limit = v
+		}
+	}
+	return condition, nil
+}
+
+// windowTotal 固定の件数の候補を並べ替える場合は、取得できるページの範囲に合わせて件数をdiversifyWindowまでとする
+func windowTotal(total int64) int64 {
+	if total > diversifyWindow {
+		return diversifyWindow
+	}
+	return total
+}
+
+// validateSeedWindow 元の商品が複数の場合も偏りをなくす場合と同じく固定の件数の候補を並べ替えるため、その範囲を超えるページはエラーとする
+func validateSeedWindow(q map[string]string, config SearchConfig) error {
+	if len(splitParameter(q, "item_id")) < 2 {
+		return nil
+	}
+	if from, size := parsePaging(q, config.DefaultLimit); from+size > diversifyWindow {
+		return domain.NewValidationError("offset", fmt.Sprintf("plus limit must be at most %d with multiple item_id", diversifyWindow))
+	}
+	return nil
+}
+
+// rerank ページ毎に同じ値の商品がlimit件までになるよう並べ替えた順序を返却する
+// 上限によりページが埋まらない場合は後回しにした商品を元の順に使う、条件がnilの場合は元の順とする
+func (condition *diversifyCondition) rerank(items []*domain.Item, size int) []int {
+	remaining := make([]int, len(items))
+	for i := range remaining {
+		remaining[i] = i
+	}
+	if condition == nil || size <= 0 {
+		return remaining
+	}
+	order := make([]int, 0, len(items))
+	for len(remaining) > 0 {
+		counts := make(map[string]int)
+		var page, deferred []int
+		for _, i := range remaining {
+			key := itemField(items[i], condition.field)
+			if len(page) < size && counts[key] < condition.limit {
+				page = append(page, i)
+				counts[key]++
+			} else {
+				deferred = append(deferred, i)
+			}
+		}
+		for len(page) < size && len(deferred) > 0 {
+			page = append(page, deferred[0])
+			deferred = deferred[1:]
+		}
+		order = append(order, page...)
+		remaining = deferred
+	}
+	return order
+}
+
+// paginateOrder 並べ替えた順序からページの分を切り出す
+func paginateOrder(order []int, from, size int) []int {
+	if from < 0 {
+		from = 0
+	}
+	if from >= len(order) || size <= 0 {
+		return nil
+	}
+	if from+size > len(order) {
+		return order[from:]
+	}
+	return order[from : from+size]
+}
+
+// seedIndex 候補がどの元の商品によるものかを性別・カテゴリが同じもの、性別が同じものの順に探す、見つからない場合は-1
+func seedIndex(item *domain.Item, seeds []*domain.Item) int {
+	for i, seed := range seeds {
+		if seed.Gender == item.Gender && seed.Category == item.Category {
+			return i
+		}
+	}
+	for i, seed := range seeds {
+		if seed.Gender == item.Gender {
+			return i
+		}
+	}
+	return -1
+}
+
+// diversifyBySeed 特定の元の商品による候補ばかりにならないよう、元の商品毎に順に候補を取り出した順序を返却する
+// 元の商品毎の候補の順序は変えず、どの元の商品にもよらない候補は最後の組とする
+func diversifyBySeed(items []*domain.Item, seeds []*domain.Item) []int {
+	groups := make([][]int, len(seeds)+1)
+	for i, item := range items {
+		seed := seedIndex(item, seeds)
+		if seed < 0 {
+			seed = len(seeds)
+		}
+		groups[seed] = append(groups[seed], i)
+	}
+	order := make([]int, 0, len(items))
+	for len(order) < len(items) {
+		for i, group := range groups {
+			if len(group) > 0 {
+				order = append(order, group[0])
+				groups[i] = group[1:]
+			}
+		}
+	}
+	return order
+}
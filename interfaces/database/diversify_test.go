@@ -0,0 +1,103 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/akaishi-sandbox/sam-go/domain"
+)
+
+func TestParseDiversify(t *testing.T) {
+	if condition, err := parseDiversify(map[string]string{}, testSearchConfig); err != nil || condition != nil {
+		t.Errorf("diversify without parameter:%v %v", condition, err)
+	}
+	if condition, err := parseDiversify(map[string]string{"diversify": "brand"}, testSearchConfig); err != nil || condition.field != "brand" || condition.limit != 3 {
+		t.Errorf("diversify brand:%+v %v", condition, err)
+	}
+	if condition, err := parseDiversify(map[string]string{"diversify": "category", "diversify_limit": "1"}, testSearchConfig); err != nil || condition.field != "category" || condition.limit != 1 {
+		t.Errorf("diversify category:%+v %v", condition, err)
+	}
+	if condition, err := parseDiversify(map[string]string{"diversify": "brand", "diversify_limit": "0"}, testSearchConfig); err != nil || condition.limit != 3 {
+		t.Errorf("invalid diversify limit:%+v %v", condition, err)
+	}
+	if _, err := parseDiversify(map[string]string{"diversify": "gender"}, testSearchConfig); err == nil {
+		t.Errorf("unsupported diversify accepted")
+	}
+	if _, err := parseDiversify(map[string]string{"diversify": "brand", "cursor": "WzFd"}, testSearchConfig); err == nil {
+		t.Errorf("diversify with cursor accepted")
+	}
+
+	// 固定の候補を超えるページは並べ替えの結果がページ毎に変わるため取得できない
+	if _, err := parseDiversify(map[string]string{"diversify": "brand", "offset": "324"}, testSearchConfig); err != nil {
+		t.Errorf("last diversify page rejected:%v", err)
+	}
+	if _, err := parseDiversify(map[string]string{"diversify": "brand", "offset": "340", "limit": "36"}, testSearchConfig); err == nil {
+		t.Errorf("diversify beyond window accepted")
+	}
+}
+
+func TestValidateSeedWindow(t *testing.T) {
+	if err := validateSeedWindow(map[string]string{"item_id": "A001", "offset": "1000"}, testSearchConfig); err != nil {
+		t.Errorf("single item rejected:%v", err)
+	}
+	if err := validateSeedWindow(map[string]string{"item_id": "A001,A002", "offset": "324"}, testSearchConfig); err != nil {
+		t.Errorf("last seed page rejected:%v", err)
+	}
+	if err := validateSeedWindow(map[string]string{"item_id": "A001,A002", "offset": "340", "limit": "36"}, testSearchConfig); err == nil {
+		t.Errorf("seed page beyond window accepted")
+	}
+}
+
+func TestDiversifyRerank(t *testing.T) {
+	items := []*domain.Item{
+		{ItemID: "A1", Brand: "UNIQLO"},
+		{ItemID: "A2", Brand: "UNIQLO"},
+		{ItemID: "A3", Brand: "UNIQLO"},
+		{ItemID: "A4", Brand: "GU"},
+		{ItemID: "A5", Brand: "UNIQLO"},
+		{ItemID: "A6", Brand: "GU"},
+		{ItemID: "A7", Brand: "UNIQLO"},
+	}
+	testCase := func(condition *diversifyCondition, size int, ok string) {
+		ids := []string{}
+		for _, i := range condition.rerank(items, size) {
+			ids = append(ids, items[i].ItemID)
+		}
+		if strings.Join(ids, " ") != ok {
+			t.Errorf("rerank error:%v <> %s", ids, ok)
+		}
+	}
+	testCase(nil, 3, "A1 A2 A3 A4 A5 A6 A7")
+	// 1ページ3件で同じブランドは2件まで、埋まらない場合は後回しにした商品を使う
+	testCase(&diversifyCondition{field: "brand", limit: 2}, 3, "A1 A2 A4 A3 A5 A6 A7")
+	testCase(&diversifyCondition{field: "brand", limit: 1}, 2, "A1 A4 A2 A6 A3 A5 A7")
+
+	if page := paginateOrder([]int{3, 1, 2, 0}, 1, 2); len(page) != 2 || page[0] != 1 || page[1] != 2 {
+		t.Errorf("paginate order error:%v", page)
+	}
+	if page := paginateOrder([]int{3, 1}, 2, 2); len(page) != 0 {
+		t.Errorf("paginate order error:%v", page)
+	}
+}
+
+func TestDiversifyBySeed(t *testing.T) {
+	seeds := []*domain.Item{
+		{ItemID: "S1", Gender: "MEN", Category: "シャツ"},
+		{ItemID: "S2", Gender: "MEN", Category: "パンツ"},
+	}
+	items := []*domain.Item{
+		{ItemID: "A1", Gender: "MEN", Category: "シャツ"},
+		{ItemID: "A2", Gender: "MEN", Category: "シャツ"},
+		{ItemID: "A3", Gender: "WOMEN", Category: "シャツ"},
+		{ItemID: "A4", Gender: "MEN", Category: "シャツ"},
+		{ItemID: "A5", Gender: "MEN", Category: "パンツ"},
+		{ItemID: "A6", Gender: "MEN", Category: "パンツ"},
+	}
+	ids := []string{}
+	for _, i := range diversifyBySeed(items, seeds) {
+		ids = append(ids, items[i].ItemID)
+	}
+	if ok := "A1 A5 A3 A2 A6 A4"; strings.Join(ids, " ") != ok {
+		t.Errorf("diversify error:%v <> %s", ids, ok)
+	}
+}
@@ -1,21 +1,23 @@
 package database
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"reflect"
-	"strconv"
+	"math"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/akaishi-sandbox/sam-go/domain"
 	"github.com/akaishi-sandbox/sam-go/infrastructure"
 	elastic "github.com/olivere/elastic/v7"
+	"golang.org/x/text/unicode/norm"
 )
 
 // ItemRepository struct
 type ItemRepository struct {
 	ElasticHandler *infrastructure.ElasticHandler
+	Config         SearchConfig
 }
 
 func newTermsString(name string, input []string) *elastic.TermsQuery {
@@ -26,268 +28,1026 @@ func newTermsString(name string, input []string) *elastic.TermsQuery {
 	return elastic.NewTermsQuery(name, values...)
 }
 
-func createSearchQuery(q map[string]string) *infrastructure.ElasticQuery {
-	query := elastic.NewBoolQuery()
-	if itemID, ok := q["item_id"]; ok && len(itemID) > 0 {
-		query = query.Filter(newTermsString("item_id", strings.Split(itemID, ",")))
-	}
-	if gender, ok := q["gender"]; ok && len(gender) > 0 {
-		query = query.Filter(newTermsString("gender", strings.Split(gender, ",")))
-	}
-	if brand, ok := q["brand"]; ok && len(brand) > 0 {
-		query = query.Filter(newTermsString("brand", strings.Split(brand, ",")))
-	}
-	if category, ok := q["category"]; ok && len(category) > 0 {
-		query = query.Filter(newTermsString("category", strings.Split(category, ",")))
+// normalizeKeywords 全角英数字・記号を半角に、半角カナを濁点を含めて全角に揃えてキーワードに分解する
+func normalizeKeywords(keywords string) []string {
+	return strings.Fields(norm.NFKC.String(keywords))
+}
+
+// priceRanges 価格の絞り込み件数の区切り
+var priceRanges = [][2]float64{{0, 1000}, {1000, 3000}, {3000, 5000}, {5000, 10000}, {10000, 20000}, {20000, 0}}
+
+// facetSize 項目毎に返却する絞り込み件数の最大数
+const facetSize = 100
+
+// highlightFields キーワードに一致した箇所を返却する項目、存在しない項目は無視される
+var highlightFields = []string{"search_text", "title", "description"}
+
+func parseFacets(q map[string]string) []string {
+	facets, ok := q["facets"]
+	if !ok || len(facets) == 0 || facets == "0" {
+		return nil
 	}
-	if discountFlag, ok := q["discount_flag"]; ok && len(discountFlag) > 0 {
-		query = query.Filter(newTermsString("discount_flag", strings.Split(discountFlag, ",")))
+	if facets == "1" {
+		return domain.FacetNames
 	}
-	if minPrice, ok := q["min_price"]; ok {
-		if price, err := strconv.Atoi(minPrice); err == nil {
-			query = query.Filter(elastic.NewRangeQuery("lowest_price").Gte(price))
+	var names []string
+	for _, facet := range strings.Split(facets, ",") {
+		for _, name := range domain.FacetNames {
+			if facet == name {
+				names = append(names, name)
+			}
 		}
 	}
-	if maxPrice, ok := q["max_price"]; ok {
-		if price, err := strconv.Atoi(maxPrice); err == nil {
-			query = query.Filter(elastic.NewRangeQuery("lowest_price").Lte(price))
+	return names
+}
+
+func createFacetAggregation(name string) elastic.Aggregation {
+	switch name {
+	case domain.FacetPrice:
+		aggregation := elastic.NewRangeAggregation().Field("lowest_price")
+		for _, r := range priceRanges {
+			switch {
+			case r[1] == 0:
+				aggregation = aggregation.AddUnboundedTo(r[0])
+			case r[0] == 0:
+				aggregation = aggregation.AddUnboundedFrom(r[1])
+			default:
+				aggregation = aggregation.AddRange(r[0], r[1])
+			}
 		}
+		return aggregation
+	default:
+		return elastic.NewTermsAggregation().Field(name).Size(facetSize)
 	}
-	var skuQuery *elastic.BoolQuery
-	if minBmi, ok := q["min_bmi"]; ok {
-		if bmi, err := strconv.ParseFloat(minBmi, 64); err == nil {
-			if skuQuery == nil {
-				skuQuery = elastic.NewBoolQuery()
+}
+
+// createFacetAggregations 選択中の項目自身の条件を除いた条件で件数を集計する
+func createFacetAggregations(names []string, filters []fieldFilter) map[string]elastic.Aggregation {
+	aggregations := make(map[string]elastic.Aggregation, len(names))
+	for _, name := range names {
+		query := elastic.NewBoolQuery()
+		for _, filter := range filters {
+			if filter.name != name {
+				query = query.Filter(filter.query())
 			}
-			skuQuery = skuQuery.Filter(elastic.NewRangeQuery("SKUs.bmi").Gte(bmi))
 		}
+		aggregations[name] = elastic.NewFilterAggregation().
+			Filter(query).
+			SubAggregation(name, createFacetAggregation(name))
 	}
-	if maxBmi, ok := q["max_bmi"]; ok {
-		if bmi, err := strconv.ParseFloat(maxBmi, 64); err == nil {
-			if skuQuery == nil {
-				skuQuery = elastic.NewBoolQuery()
-			}
-			skuQuery = skuQuery.Filter(elastic.NewRangeQuery("SKUs.bmi").Lte(bmi))
+	return aggregations
+}
+
+// createHighlight 商品名や説明に含まれるタグがそのまま表示されないよう、一致した箇所以外はHTMLエスケープして返却する
+func createHighlight() *elastic.Highlight {
+	fields := make([]*elastic.HighlighterField, len(highlightFields))
+	for i, field := range highlightFields {
+		fields[i] = elastic.NewHighlighterField(field)
+	}
+	return elastic.NewHighlight().
+		Fields(fields...).
+		Encoder("html").
+		PreTags("<em>").
+		PostTags("</em>").
+		FragmentSize(100).
+		NumOfFragments(3)
+}
+
+func createSearchQuery(q map[string]string, config SearchConfig) (*infrastructure.ElasticQuery, error) {
+	condition, err := parseSearchCondition(q, config)
+	if err != nil {
+		return nil, err
+	}
+	return createConditionQuery(condition, config), nil
+}
+
+// createConditionQuery 解析した条件から商品検索のクエリを作成する
+func createConditionQuery(condition *searchCondition, config SearchConfig) *infrastructure.ElasticQuery {
+	query := elastic.NewBoolQuery()
+	if len(condition.itemIDs) > 0 {
+		query = query.Filter(newTermsString("item_id", condition.itemIDs))
+	}
+	// 絞り込み件数を返却する場合、他の項目の件数が絞られないよう項目の条件はpost_filterで評価する
+	var postFilter elastic.Query
+	if len(condition.facets) == 0 {
+		for _, filter := range condition.filters {
+			query = query.Filter(filter.query())
+		}
+	} else if len(condition.filters) > 0 {
+		postQuery := elastic.NewBoolQuery()
+		for _, filter := range condition.filters {
+			postQuery = postQuery.Filter(filter.query())
 		}
+		postFilter = postQuery
 	}
-	if skuQuery != nil {
+	if condition.minBmi != nil || condition.maxBmi != nil {
+		skuQuery := elastic.NewBoolQuery()
+		if condition.minBmi != nil {
+			skuQuery = skuQuery.Filter(elastic.NewRangeQuery("SKUs.bmi").Gte(*condition.minBmi))
+		}
+		if condition.maxBmi != nil {
+			skuQuery = skuQuery.Filter(elastic.NewRangeQuery("SKUs.bmi").Lte(*condition.maxBmi))
+		}
 		// bmi条件の時はstockがあることの確認
 		skuQuery = skuQuery.Filter(elastic.NewRangeQuery("SKUs.stock").Gte(1))
 		query = query.Must(elastic.NewNestedQuery("SKUs", skuQuery))
 	}
-	if keywords, ok := q["keywords"]; ok && len(keywords) > 0 {
-		// 全角スペースを半角スペースにした後、半角スペースで分解する
-		for _, keyword := range strings.Split(strings.NewReplacer("　", " ").Replace(keywords), " ") {
-			// キーワードの中にスラッシュがある場合ORとして評価する
-			if strings.Index(keyword, "/") != -1 {
-				q := elastic.NewBoolQuery()
-				for _, parseWord := range strings.Split(keyword, "/") {
-					q.Should(elastic.NewMatchPhraseQuery("search_text", parseWord))
-				}
-				query = query.Must(q)
-			} else {
-				// スラッシュを含んでいない場合はANDとして評価する
-				query = query.Must(elastic.NewMatchPhraseQuery("search_text", keyword))
+	if condition.keywords != nil {
+		query = applyKeywords(query, condition.keywords)
+	}
+	if condition.excludeExpired {
+		query = query.Filter(elastic.NewTermsQuery("release_flag", 0, 1))
+	}
+	var scoredQuery elastic.Query = query
+	if condition.halfLife > 0 {
+		scoredQuery = createPopularityQuery(query, condition.halfLife, condition.origin)
+	}
+
+	// カーソルで続きを取得できるよう、同じ値の商品の並びはitem_idで確定させる
+	eq := &infrastructure.ElasticQuery{
+		Index:       config.Indices.Items,
+		Query:       scoredQuery,
+		PostFilter:  postFilter,
+		Sort:        []elastic.Sorter{condition.sort, elastic.SortInfo{Field: "item_id", Ascending: true}},
+		SearchAfter: condition.cursor.After,
+		From:        condition.from,
+		Size:        condition.size,
+	}
+	if len(condition.facets) > 0 {
+		eq.Aggregations = createFacetAggregations(condition.facets, condition.filters)
+	}
+	if condition.highlight {
+		eq.Highlight = createHighlight()
+	}
+	return eq
+}
+
+// excludeItemIDs 元の商品を候補から除外する
+func excludeItemIDs(query *elastic.BoolQuery, itemIDs []string) *elastic.BoolQuery {
+	if len(itemIDs) == 1 {
+		return query.MustNot(elastic.NewTermQuery("item_id", itemIDs[0]))
+	}
+	return query.MustNot(newTermsString("item_id", itemIDs))
+}
+
+// createRecommendItems 元の商品と性別・カテゴリが同じ商品、元の商品が複数の場合はいずれかと同じもの
+func createRecommendItems(items []*domain.Item, q map[string]string, config SearchConfig) *infrastructure.ElasticQuery {
+	query := elastic.NewBoolQuery()
+	if itemIDs := splitParameter(q, "item_id"); len(itemIDs) > 0 {
+		query = excludeItemIDs(query, itemIDs)
+	}
+	if brand, ok := q["brand"]; ok {
+		query = query.Filter(newTermsString("brand", strings.Split(brand, ",")))
+	}
+	var pairs []*domain.Item
+	for _, item := range items {
+		duplicated := false
+		for _, pair := range pairs {
+			if pair.Gender == item.Gender && pair.Category == item.Category {
+				duplicated = true
+				break
 			}
 		}
+		if !duplicated {
+			pairs = append(pairs, item)
+		}
+	}
+	if len(pairs) == 1 {
+		query = query.Filter(newTermsString("gender", strings.Split(pairs[0].Gender, ",")))
+		query = query.Filter(newTermsString("category", strings.Split(pairs[0].Category, ",")))
+	} else {
+		should := elastic.NewBoolQuery()
+		for _, pair := range pairs {
+			should = should.Should(elastic.NewBoolQuery().
+				Filter(newTermsString("gender", strings.Split(pair.Gender, ","))).
+				Filter(newTermsString("category", strings.Split(pair.Category, ","))))
+		}
+		query = query.Filter(should)
 	}
 
-	if excludeExpired, ok := q["exclude_expired"]; !ok && excludeExpired == "1" {
-		query = query.Filter(elastic.NewTermsQuery("release_flag", 0, 1))
+	from, size := parsePaging(q, config.DefaultLimit)
+
+	return &infrastructure.ElasticQuery{
+		Index: config.Indices.Items,
+		Query: query,
+		From:  from,
+		Size:  size,
 	}
+}
 
-	from := 0
-	if offset, ok := q["offset"]; ok {
-		if v, err := strconv.Atoi(offset); err == nil {
-			from = v
+// recommendStrategy おすすめ商品の選び方、指定されていない場合は同じカテゴリの商品とする
+func recommendStrategy(q map[string]string) (string, error) {
+	strategy, ok := q["strategy"]
+	if !ok || len(strategy) == 0 {
+		return domain.RecommendSameCategory, nil
+	}
+	if !domain.RecommendStrategies.Contains(strategy) {
+		return "", domain.NewValidationError("strategy", "is not supported")
+	}
+	return strategy, nil
+}
+
+// recommendFallbacks fallbackで代わりに使う方法を順に指定する、noneの場合は使わない
+func recommendFallbacks(q map[string]string, config SearchConfig) ([]string, error) {
+	fallback, ok := q["fallback"]
+	if !ok || len(fallback) == 0 {
+		return config.RecommendFallbacks, nil
+	}
+	if fallback == "none" {
+		return nil, nil
+	}
+	tiers := strings.Split(fallback, ",")
+	for _, tier := range tiers {
+		if !domain.RecommendFallbacks.Contains(tier) {
+			return nil, domain.NewValidationError("fallback", "is not supported")
 		}
 	}
-	size := 36
-	if limit, ok := q["limit"]; ok {
-		if v, err := strconv.Atoi(limit); err == nil {
-			size = v
+	return tiers, nil
+}
+
+// createSameGenderItems 元の商品のいずれかと性別が同じ商品
+func createSameGenderItems(items []*domain.Item, q map[string]string, config SearchConfig) *infrastructure.ElasticQuery {
+	query := elastic.NewBoolQuery()
+	query = query.MustNot(newTermsString("item_id", splitParameter(q, "item_id")))
+	if brand, ok := q["brand"]; ok {
+		query = query.Filter(newTermsString("brand", strings.Split(brand, ",")))
+	}
+	var genders []string
+	for _, item := range items {
+		for _, gender := range strings.Split(item.Gender, ",") {
+			if !containsString(genders, gender) {
+				genders = append(genders, gender)
+			}
 		}
 	}
+	query = query.Filter(newTermsString("gender", genders))
 
-	sort := elastic.SortInfo{Field: "updated_at", Ascending: false}
+	from, size := parsePaging(q, config.DefaultLimit)
 
-	if order, ok := q["order"]; ok {
-		switch order {
-		case "new":
-		case "min-max":
-			sort.Field = "lowest_price"
-			sort.Ascending = true
-		case "max-max":
-			sort.Field = "lowest_price"
-			sort.Ascending = false
-		}
+	return &infrastructure.ElasticQuery{
+		Index: config.Indices.Items,
+		Query: query,
+		From:  from,
+		Size:  size,
 	}
+}
+
+// createPopularItems 元の商品に関わらず人気順の商品、元の商品が存在しない場合にも使う
+func createPopularItems(q map[string]string, config SearchConfig) *infrastructure.ElasticQuery {
+	query := elastic.NewBoolQuery()
+	query = query.MustNot(newTermsString("item_id", splitParameter(q, "item_id")))
+	if brand, ok := q["brand"]; ok {
+		query = query.Filter(newTermsString("brand", strings.Split(brand, ",")))
+	}
+	from, size := parsePaging(q, config.DefaultLimit)
 
 	return &infrastructure.ElasticQuery{
-		Index:    "items",
-		Query:    query,
-		SortInfo: sort,
-		From:     from,
-		Size:     size,
+		Index: config.Indices.Items,
+		Query: createPopularityQuery(query, config.PopularityHalfLife, time.Time{}),
+		Sort:  []elastic.Sorter{elastic.NewScoreSort(), elastic.NewFieldSort("item_id").Asc()},
+		From:  from,
+		Size:  size,
 	}
 }
 
-func createRecommendItems(item domain.Item, q map[string]string) *infrastructure.ElasticQuery {
+// similarFields 内容が似ている商品を探す際に比較する項目
+var similarFields = []string{"search_text", "title", "brand"}
+
+// similarPriceScale 元の商品との価格差がこの割合になるとスコアが半分になる、安い商品は最低価格差を使う
+const (
+	similarPriceScale    = 0.3
+	similarMinPriceScale = 1000
+)
+
+// similarPriceDistance 基準の価格に対してスコアが半分になる価格差
+func similarPriceDistance(price int) float64 {
+	return math.Max(float64(price)*similarPriceScale, similarMinPriceScale)
+}
+
+// createSimilarItems 元の商品と内容が似ていて価格が近い順に並べる、元の商品が複数の場合は平均の価格を基準とする
+func createSimilarItems(items []*domain.Item, hits []*elastic.SearchHit, q map[string]string, config SearchConfig) *infrastructure.ElasticQuery {
+	like := make([]*elastic.MoreLikeThisQueryItem, 0, len(hits))
+	for _, hit := range hits {
+		like = append(like, elastic.NewMoreLikeThisQueryItem().Index(hit.Index).Id(hit.Id))
+	}
+	itemIDs := make([]string, 0, len(items))
+	price := 0
+	for _, item := range items {
+		itemIDs = append(itemIDs, item.ItemID)
+		price += item.LowestPrice
+	}
+	price /= len(items)
+
 	query := elastic.NewBoolQuery()
-	if itemID, ok := q["item_id"]; ok {
-		query = query.MustNot(elastic.NewTermQuery("item_id", itemID))
+	query = query.Must(elastic.NewMoreLikeThisQuery().
+		Field(similarFields...).
+		LikeItems(like...).
+		MinTermFreq(1).
+		MinDocFreq(1).
+		MaxQueryTerms(25))
+	query = excludeItemIDs(query, itemIDs)
+	if brand, ok := q["brand"]; ok {
+		query = query.Filter(newTermsString("brand", strings.Split(brand, ",")))
 	}
+
+	from, size := parsePaging(q, config.DefaultLimit)
+
+	return &infrastructure.ElasticQuery{
+		Index: config.Indices.Items,
+		Query: elastic.NewFunctionScoreQuery().
+			Query(query).
+			AddScoreFunc(elastic.NewGaussDecayFunction().FieldName("lowest_price").Origin(price).Scale(similarPriceDistance(price)).Decay(0.5)).
+			BoostMode("multiply"),
+		From: from,
+		Size: size,
+	}
+}
+
+// coViewSessionSize 一緒に閲覧された商品を集計する対象のセッション数、元の商品を多く閲覧したセッションを優先する
+// coViewItemSize 絞り込んでからページを切り出す候補の件数、ページ毎に候補が変わらないよう固定の件数とし、この範囲を超える商品は返却しない
+const (
+	coViewSessionSize = 1000
+	coViewItemSize    = 1000
+)
+
+// createCoViewSessionsQuery 元の商品を閲覧したセッションを集計する
+func createCoViewSessionsQuery(itemIDs []string, config SearchConfig) *infrastructure.ElasticQuery {
+	return &infrastructure.ElasticQuery{
+		Index: config.Indices.AccessEvents,
+		Query: elastic.NewBoolQuery().Filter(newTermsString("item_id", itemIDs)),
+		Aggregations: map[string]elastic.Aggregation{
+			"sessions": elastic.NewTermsAggregation().Field("session_id").Size(coViewSessionSize),
+		},
+		Size: 0,
+	}
+}
+
+// createCoViewItemsQuery 同じセッションで閲覧された商品を閲覧したセッション数の多い順に候補の件数まで集計する
+func createCoViewItemsQuery(itemIDs, sessions []string, config SearchConfig) *infrastructure.ElasticQuery {
+	query := elastic.NewBoolQuery().
+		Filter(newTermsString("session_id", sessions)).
+		MustNot(newTermsString("item_id", itemIDs))
+	return &infrastructure.ElasticQuery{
+		Index: config.Indices.AccessEvents,
+		Query: query,
+		Aggregations: map[string]elastic.Aggregation{
+			"items": elastic.NewTermsAggregation().Field("item_id").Size(coViewItemSize).
+				SubAggregation("sessions", elastic.NewCardinalityAggregation().Field("session_id")).
+				OrderByAggregation("sessions", false).
+				OrderByKeyAsc(),
+		},
+		Size: 0,
+	}
+}
+
+// createCoViewedItems 集計した商品を他の方法と同じくbrandで絞り込んで取得する、同じitem_idのドキュメントはまとめる
+func createCoViewedItems(coViewed []string, q map[string]string, config SearchConfig) *infrastructure.ElasticQuery {
+	query := elastic.NewBoolQuery().Filter(newTermsString("item_id", coViewed))
 	if brand, ok := q["brand"]; ok {
 		query = query.Filter(newTermsString("brand", strings.Split(brand, ",")))
 	}
-	query = query.Filter(newTermsString("gender", strings.Split(item.Gender, ",")))
-	query = query.Filter(newTermsString("category", strings.Split(item.Category, ",")))
+	return &infrastructure.ElasticQuery{
+		Index:    config.Indices.Items,
+		Query:    query,
+		Collapse: elastic.NewCollapseBuilder("item_id"),
+		From:     0,
+		Size:     len(coViewed),
+	}
+}
+
+func bucketKeys(aggregations elastic.Aggregations, name string) []string {
+	keys := []string{}
+	if terms, ok := aggregations.Terms(name); ok {
+		for _, bucket := range terms.Buckets {
+			keys = append(keys, fmt.Sprint(bucket.Key))
+		}
+	}
+	return keys
+}
+
+// recommendCoViewed 元の商品と同じセッションで閲覧された商品を返却する
+// 削除された商品や条件に合わない商品を除いてからページを切り出し、件数は取得できた商品の数とする
+func (repo *ItemRepository) recommendCoViewed(ctx context.Context, itemIDs []string, diversify *diversifyCondition, q map[string]string, fit bool, bmi float64) (*domain.SearchResult, error) {
+	searchResult, err := repo.ElasticHandler.Search(ctx, createCoViewSessionsQuery(itemIDs, repo.Config))
+	if err != nil {
+		return nil, err
+	}
+	sessions := bucketKeys(searchResult.Aggregations, "sessions")
+	if len(sessions) == 0 {
+		return &domain.SearchResult{Items: []*domain.SearchItem{}}, nil
+	}
 
-	from := 0
-	if offset, ok := q["offset"]; ok {
-		if v, err := strconv.Atoi(offset); err == nil {
-			from = v
+	searchResult, err = repo.ElasticHandler.Search(ctx, createCoViewItemsQuery(itemIDs, sessions, repo.Config))
+	if err != nil {
+		return nil, err
+	}
+	coViewed := bucketKeys(searchResult.Aggregations, "items")
+	if len(coViewed) == 0 {
+		return &domain.SearchResult{Items: []*domain.SearchItem{}}, nil
+	}
+
+	itemsQuery := createCoViewedItems(coViewed, q, repo.Config)
+	if fit {
+		applySizeFit(itemsQuery, bmi)
+	}
+	searchResult, err = repo.ElasticHandler.Search(ctx, itemsQuery)
+	if err != nil {
+		return nil, err
+	}
+	result, err := newSearchResult(searchResult)
+	if err != nil {
+		return nil, err
+	}
+	// 集計した順に並べ替える、削除された商品は含まれない
+	ranks := make(map[string]int, len(coViewed))
+	for i, itemID := range coViewed {
+		ranks[itemID] = i
+	}
+	sort.SliceStable(result.Items, func(i, j int) bool {
+		return ranks[result.Items[i].ItemID] < ranks[result.Items[j].ItemID]
+	})
+	from, size := parsePaging(q, repo.Config.DefaultLimit)
+	result.Total = int64(len(result.Items))
+	if diversify != nil {
+		result.Total = windowTotal(result.Total)
+	}
+	result.Items = pageSearchItems(result.Items, diversify.rerank(searchItems(result.Items), size), from, size)
+	return result, nil
+}
+
+// sizeFitBmiRange 体型に合うとみなすSKUのBMIの差、sizeFitBmiScaleの差でスコアが半分になる
+const (
+	sizeFitBmiRange = 3.0
+	sizeFitBmiScale = 1.5
+)
+
+// sizeFitInnerHit 最も体型に合うSKUを返却する際のinner_hitsの名前
+const sizeFitInnerHit = "fit_sku"
+
+// createSizeFitQuery 在庫のあるSKUのうちBMIが最も近いものでスコアを求め、合うSKUがない商品は除外する
+func createSizeFitQuery(bmi float64) elastic.Query {
+	sku := elastic.NewBoolQuery().
+		Filter(elastic.NewRangeQuery("SKUs.stock").Gte(1)).
+		Filter(elastic.NewRangeQuery("SKUs.bmi").Gte(bmi - sizeFitBmiRange).Lte(bmi + sizeFitBmiRange))
+	return elastic.NewNestedQuery("SKUs", elastic.NewFunctionScoreQuery().
+		Query(sku).
+		AddScoreFunc(elastic.NewGaussDecayFunction().FieldName("SKUs.bmi").Origin(bmi).Scale(sizeFitBmiScale).Decay(0.5)).
+		BoostMode("replace")).
+		ScoreMode("max").
+		InnerHit(elastic.NewInnerHit().Name(sizeFitInnerHit).Size(1))
+}
+
+// applySizeFit おすすめ商品の条件に体型に合うSKUがあることを加え、合う順に並べる
+func applySizeFit(eq *infrastructure.ElasticQuery, bmi float64) {
+	eq.Query = elastic.NewBoolQuery().Must(eq.Query, createSizeFitQuery(bmi))
+}
+
+// classificationParameters 分類の絞り込み以外に指定できるパラメータ
+var classificationParameters = []string{"index", "mode", "offset", "limit"}
+
+// classificationSource indexで指定された分類の設定を返却する、設定にない絞り込みの項目はエラーとする
+func classificationSource(q map[string]string, config SearchConfig) (string, ClassificationSource, error) {
+	name, ok := q["index"]
+	if !ok {
+		return "", ClassificationSource{}, domain.NewValidationError("index", "is required")
+	}
+	source, ok := config.ClassificationSources[name]
+	if !ok {
+		return "", ClassificationSource{}, domain.NewValidationError("index", "is not supported")
+	}
+	for parameter := range q {
+		if !containsString(classificationParameters, parameter) && !containsString(source.Filters, parameter) {
+			return "", ClassificationSource{}, domain.NewValidationError(parameter, "is not supported for "+name)
 		}
 	}
-	size := 36
-	if limit, ok := q["limit"]; ok {
-		if v, err := strconv.Atoi(limit); err == nil {
-			size = v
+	if len(source.Index) == 0 {
+		switch name {
+		case "categories":
+			source.Index = config.Indices.Categories
+		case "brands":
+			source.Index = config.Indices.Brands
+		default:
+			source.Index = name
 		}
 	}
+	if len(source.Sort) == 0 {
+		source.Sort = "sort_no"
+	}
+	return name, source, nil
+}
+
+func createClassificationQuery(q map[string]string, config SearchConfig) (*infrastructure.ElasticQuery, error) {
+	_, source, err := classificationSource(q, config)
+	if err != nil {
+		return nil, err
+	}
+	query := elastic.NewBoolQuery()
+	for _, filter := range source.Filters {
+		if value, ok := q[filter]; ok {
+			query = query.Filter(newTermsString(filter, strings.Split(value, ",")))
+		}
+	}
+	from, size := parsePaging(q, config.ClassificationLimit)
+
+	sort := []elastic.Sorter{elastic.SortInfo{Field: source.Sort, Ascending: !source.Descending}}
 
 	return &infrastructure.ElasticQuery{
-		Index: "items",
+		Index: source.Index,
 		Query: query,
+		Sort:  sort,
 		From:  from,
 		Size:  size,
+	}, nil
+}
+
+// classificationTreeSize 階層構造で返却する分類の最大数
+const classificationTreeSize = 1000
+
+// classificationTree mode=treeの場合は分類を階層構造で返却する、カテゴリのみ対応する
+// 親の分類が除かれて階層が崩れないよう、絞り込みは商品の件数にも使うgenderのみとする
+func classificationTree(q map[string]string) (bool, error) {
+	switch q["mode"] {
+	case "", domain.ClassificationList:
+		return false, nil
+	case domain.ClassificationTree:
+		if q["index"] != "categories" {
+			return false, domain.NewValidationError("mode", "tree supports only categories")
+		}
+		parameters := make([]string, 0, len(q))
+		for parameter := range q {
+			parameters = append(parameters, parameter)
+		}
+		sort.Strings(parameters)
+		for _, parameter := range parameters {
+			if parameter != "gender" && !containsString(classificationParameters, parameter) {
+				return false, domain.NewValidationError(parameter, "can not be used with tree mode")
+			}
+		}
+		return true, nil
+	default:
+		return false, domain.NewValidationError("mode", "is not supported")
 	}
 }
 
-func createClassificationQuery(q map[string]string) (*infrastructure.ElasticQuery, error) {
-	query := elastic.NewBoolQuery()
+// createClassificationTreeQueries 全てのカテゴリを設定された項目で並べ、itemsのカテゴリ毎の商品件数を集計する
+func createClassificationTreeQueries(q map[string]string, source ClassificationSource, config SearchConfig) []*infrastructure.ElasticQuery {
+	classificationQuery := elastic.NewBoolQuery()
+	itemQuery := elastic.NewBoolQuery()
 	if gender, ok := q["gender"]; ok {
-		query = query.Filter(newTermsString("gender", strings.Split(gender, ",")))
+		classificationQuery = classificationQuery.Filter(newTermsString("gender", strings.Split(gender, ",")))
+		itemQuery = itemQuery.Filter(newTermsString("gender", strings.Split(gender, ",")))
 	}
-	if title, ok := q["title"]; ok {
-		query = query.Filter(newTermsString("title", strings.Split(title, ",")))
+	return []*infrastructure.ElasticQuery{
+		{
+			Index: source.Index,
+			Query: classificationQuery,
+			Sort:  []elastic.Sorter{elastic.SortInfo{Field: source.Sort, Ascending: !source.Descending}},
+			From:  0,
+			Size:  classificationTreeSize,
+		},
+		{
+			Index: config.Indices.Items,
+			Query: itemQuery,
+			Aggregations: map[string]elastic.Aggregation{
+				"categories": elastic.NewTermsAggregation().Field("category").Size(classificationTreeSize),
+			},
+			Size: 0,
+		},
 	}
-	from := 0
-	if offset, ok := q["offset"]; ok {
-		if v, err := strconv.Atoi(offset); err == nil {
-			from = v
-		}
+}
+
+// createBrandQuery idで指定されたブランドを取得する
+func createBrandQuery(q map[string]string, config SearchConfig) (*infrastructure.ElasticQuery, error) {
+	id, ok := q["id"]
+	if !ok || len(id) == 0 {
+		return nil, domain.NewValidationError("id", "is required")
 	}
-	size := 100
-	if limit, ok := q["limit"]; ok {
-		if v, err := strconv.Atoi(limit); err == nil {
-			size = v
-		}
+	return &infrastructure.ElasticQuery{
+		Index: config.Indices.Brands,
+		Query: elastic.NewIdsQuery().Ids(id),
+		From:  0,
+		Size:  1,
+	}, nil
+}
+
+// createBrandItemsQuery ブランドの商品の価格の範囲、カテゴリ・性別毎の件数、値引きしている件数を集計する
+func createBrandItemsQuery(brand *domain.Classification, config SearchConfig) *infrastructure.ElasticQuery {
+	return &infrastructure.ElasticQuery{
+		Index: config.Indices.Items,
+		Query: elastic.NewBoolQuery().Filter(elastic.NewTermQuery("brand", brand.Title)),
+		Aggregations: map[string]elastic.Aggregation{
+			"min_price":  elastic.NewMinAggregation().Field("lowest_price"),
+			"max_price":  elastic.NewMaxAggregation().Field("lowest_price"),
+			"categories": elastic.NewTermsAggregation().Field("category").Size(facetSize),
+			"genders":    elastic.NewTermsAggregation().Field("gender").Size(facetSize),
+			"discount":   elastic.NewFilterAggregation().Filter(elastic.NewTermQuery("discount_flag", 1)),
+		},
+		TrackTotalHits: true,
+		Size:           0,
 	}
+}
+
+// suggestSize 種類毎に返却する候補の既定の最大数
+const suggestSize = 10
+
+// newSuggestQuery 入力の全ての語が語の先頭に一致する、.suggestはedge_ngramで索引したサブフィールド
+func newSuggestQuery(field, text string) elastic.Query {
+	return elastic.NewMatchQuery(field+".suggest", text).Operator("and")
+}
 
-	sort := elastic.SortInfo{Field: "sort_no", Ascending: true}
+// newSuggestAggregation 入力に一致する値とその商品数を集計する
+func newSuggestAggregation(field, termsField, text string, size int) elastic.Aggregation {
+	return elastic.NewFilterAggregation().
+		Filter(newSuggestQuery(field, text)).
+		SubAggregation("values", elastic.NewTermsAggregation().Field(termsField).Size(size))
+}
 
-	index, ok := q["index"]
+// parseSuggestText 正規化した入力文字列と返却する候補の最大数を取得する
+func parseSuggestText(q map[string]string) (string, int, error) {
+	keywords, ok := q["keywords"]
 	if !ok {
-		return nil, fmt.Errorf("parameter not found")
-	}
-	switch index {
-	case "categories", "brands":
-		return &infrastructure.ElasticQuery{
-			Index:    index,
-			Query:    query,
-			SortInfo: sort,
-			From:     from,
-			Size:     size,
-		}, nil
-	default:
-		return nil, fmt.Errorf("not supported index")
+		return "", 0, domain.NewValidationError("keywords", "is required")
+	}
+	words := normalizeKeywords(keywords)
+	if len(words) == 0 {
+		return "", 0, domain.NewValidationError("keywords", "is required")
+	}
+	_, size := parsePaging(q, suggestSize)
+	return strings.Join(words, " "), size, nil
+}
+
+// createSuggestQueries brands, categories, itemsの順に候補を取得する検索を作成する
+func createSuggestQueries(q map[string]string, config SearchConfig) ([]*infrastructure.ElasticQuery, error) {
+	text, size, err := parseSuggestText(q)
+	if err != nil {
+		return nil, err
+	}
+
+	itemQuery := elastic.NewBoolQuery()
+	classificationQuery := elastic.NewBoolQuery().Filter(newSuggestQuery("title", text))
+	if gender, ok := q["gender"]; ok && len(gender) > 0 {
+		itemQuery = itemQuery.Filter(newTermsString("gender", strings.Split(gender, ",")))
+		classificationQuery = classificationQuery.Filter(newTermsString("gender", strings.Split(gender, ",")))
 	}
+	sort := []elastic.Sorter{elastic.SortInfo{Field: "sort_no", Ascending: true}}
 
+	// キーワードは入力に一致する商品名、ブランド・カテゴリの商品件数はitemsの同じ入力に一致する値を集計する
+	return []*infrastructure.ElasticQuery{
+		{
+			Index: config.Indices.Brands,
+			Query: classificationQuery,
+			Sort:  sort,
+			Size:  size,
+		},
+		{
+			Index: config.Indices.Categories,
+			Query: classificationQuery,
+			Sort:  sort,
+			Size:  size,
+		},
+		{
+			Index: config.Indices.Items,
+			Query: itemQuery,
+			Size:  0,
+			Aggregations: map[string]elastic.Aggregation{
+				"keywords":   newSuggestAggregation("title", "title.keyword", text, size),
+				"brands":     newSuggestAggregation("brand", "brand", text, facetSize),
+				"categories": newSuggestAggregation("category", "category", text, facetSize),
+			},
+		},
+	}, nil
 }
 
 // Search function
-func (repo *ItemRepository) Search(q map[string]string) (*elastic.SearchResult, error) {
-	return repo.ElasticHandler.Search(createSearchQuery(q))
+func (repo *ItemRepository) Search(ctx context.Context, q map[string]string) (*domain.SearchResult, error) {
+	condition, err := parseSearchCondition(q, repo.Config)
+	if err != nil {
+		return nil, err
+	}
+	query := createConditionQuery(condition, repo.Config)
+	diversify, err := parseDiversify(q, repo.Config)
+	if err != nil {
+		return nil, err
+	}
+	from, size := query.From, query.Size
+	if diversify != nil {
+		query.From, query.Size = 0, diversifyWindow
+	}
+	searchResult, err := repo.ElasticHandler.Search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	result, err := newSearchResult(searchResult)
+	if err != nil {
+		return nil, err
+	}
+	if diversify != nil {
+		// 並べ替えた結果はsearch_afterで続きを取得できないためカーソルは返却しない
+		result.Items = pageSearchItems(result.Items, diversify.rerank(searchItems(result.Items), size), from, size)
+		result.Total = windowTotal(result.Total)
+		return result, nil
+	}
+	result.Cursor = newCursor(searchResult.Hits.Hits, condition.order, condition.origin, size)
+	return result, nil
+}
+
+func searchItems(items []*domain.SearchItem) []*domain.Item {
+	converted := make([]*domain.Item, 0, len(items))
+	for _, item := range items {
+		converted = append(converted, item.Item)
+	}
+	return converted
+}
+
+// pageSearchItems 並べ替えた順序でページの分の商品を返却する
+func pageSearchItems(items []*domain.SearchItem, order []int, from, size int) []*domain.SearchItem {
+	page := []*domain.SearchItem{}
+	for _, i := range paginateOrder(order, from, size) {
+		page = append(page, items[i])
+	}
+	return page
+}
+
+// createRecommendSourcesQuery 同じitem_idのドキュメントが複数あっても他の商品が取得できなくならないようitem_id毎に1件にまとめる
+func createRecommendSourcesQuery(itemIDs []string, config SearchConfig) *infrastructure.ElasticQuery {
+	return &infrastructure.ElasticQuery{
+		Index:    config.Indices.Items,
+		Query:    elastic.NewBoolQuery().Filter(newTermsString("item_id", itemIDs)),
+		Collapse: elastic.NewCollapseBuilder("item_id"),
+		From:     0,
+		Size:     len(itemIDs),
+	}
+}
+
+// recommendSources おすすめ商品の元の商品をまとめて取得する、指定した順に並べ、存在しない商品は含めない
+func (repo *ItemRepository) recommendSources(ctx context.Context, itemIDs []string) ([]*domain.Item, []*elastic.SearchHit, error) {
+	searchResult, err := repo.ElasticHandler.Search(ctx, createRecommendSourcesQuery(itemIDs, repo.Config))
+	if err != nil {
+		return nil, nil, err
+	}
+	found := make(map[string]int)
+	var items []*domain.Item
+	var hits []*elastic.SearchHit
+	if searchResult.Hits != nil {
+		for _, hit := range searchResult.Hits.Hits {
+			item, err := newItem(hit)
+			if err != nil {
+				return nil, nil, err
+			}
+			if _, ok := found[item.ItemID]; !ok {
+				found[item.ItemID] = len(items)
+				items = append(items, item)
+				hits = append(hits, hit)
+			}
+		}
+	}
+	sortedItems := make([]*domain.Item, 0, len(items))
+	sortedHits := make([]*elastic.SearchHit, 0, len(hits))
+	for _, itemID := range itemIDs {
+		if i, ok := found[itemID]; ok {
+			sortedItems = append(sortedItems, items[i])
+			sortedHits = append(sortedHits, hits[i])
+			delete(found, itemID)
+		}
+	}
+	return sortedItems, sortedHits, nil
+}
+
+// searchRecommendItems 元の商品が複数の場合や偏りをなくす場合はページに関わらず固定の件数の候補を取得して並べ替えてからページを切り出す
+// 元の商品が複数の場合は元の商品毎に交互に並べる
+func (repo *ItemRepository) searchRecommendItems(ctx context.Context, recommendQuery *infrastructure.ElasticQuery, items []*domain.Item, diversify *diversifyCondition, fit bool, bmi float64) (*domain.SearchResult, error) {
+	from, size := recommendQuery.From, recommendQuery.Size
+	rerank := len(items) > 1 || diversify != nil
+	if rerank {
+		recommendQuery.From, recommendQuery.Size = 0, diversifyWindow
+	}
+	if fit {
+		applySizeFit(recommendQuery, bmi)
+	}
+	searchResult, err := repo.ElasticHandler.Search(ctx, recommendQuery)
+	if err != nil {
+		return nil, err
+	}
+	result, err := newSearchResult(searchResult)
+	if err != nil {
+		return nil, err
+	}
+	if !rerank {
+		return result, nil
+	}
+	candidates := result.Items
+	if len(items) > 1 {
+		candidates = make([]*domain.SearchItem, 0, len(result.Items))
+		for _, i := range diversifyBySeed(searchItems(result.Items), items) {
+			candidates = append(candidates, result.Items[i])
+		}
+	}
+	result.Items = pageSearchItems(candidates, diversify.rerank(searchItems(candidates), size), from, size)
+	result.Total = windowTotal(result.Total)
+	return result, nil
 }
 
 // Recommend function
-func (repo *ItemRepository) Recommend(q map[string]string) (*elastic.SearchResult, error) {
-	query := elastic.NewBoolQuery()
+// 指定した方法で見つからない場合はfallbackの方法を順に試す、strictの場合は試さずに元の商品が存在しなければエラーとする
+func (repo *ItemRepository) Recommend(ctx context.Context, q map[string]string) (*domain.SearchResult, error) {
 	itemID, ok := q["item_id"]
 	if !ok {
-		return nil, fmt.Errorf("parameter not found")
+		return nil, domain.NewValidationError("item_id", "is required")
 	}
-
-	query = query.Filter(elastic.NewTermQuery("item_id", itemID))
-
-	searchResult, err := repo.ElasticHandler.Search(&infrastructure.ElasticQuery{
-		Index: "items",
-		Query: query,
-		From:  0,
-		Size:  1,
-	})
+	strategy, err := recommendStrategy(q)
 	if err != nil {
 		return nil, err
 	}
+	fallbacks, err := recommendFallbacks(q, repo.Config)
+	if err != nil {
+		return nil, err
+	}
+	bmi, fit, err := parseFitBmi(q)
+	if err != nil {
+		return nil, err
+	}
+	diversify, err := parseDiversify(q, repo.Config)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateSeedWindow(q, repo.Config); err != nil {
+		return nil, err
+	}
+	strict := q["strict"] == "1"
 
-	var item domain.Item
-	if err := json.Unmarshal(searchResult.Hits.Hits[0].Source, &item); err != nil {
+	itemIDs := strings.Split(itemID, ",")
+	items, hits, err := repo.recommendSources(ctx, itemIDs)
+	if err != nil {
 		return nil, err
 	}
+	if len(items) == 0 && strict {
+		return nil, &domain.NotFoundError{Resource: "item", ID: itemID}
+	}
 
-	return repo.ElasticHandler.Search(createRecommendItems(item, q))
+	tiers := []string{strategy}
+	if !strict {
+		tiers = append(tiers, fallbacks...)
+	}
+	var result *domain.SearchResult
+	for _, tier := range tiers {
+		var recommendQuery *infrastructure.ElasticQuery
+		switch {
+		case tier == domain.RecommendCoViewed:
+			if result, err = repo.recommendCoViewed(ctx, itemIDs, diversify, q, fit, bmi); err != nil {
+				return nil, err
+			}
+		case tier == domain.RecommendPopular:
+			recommendQuery = createPopularItems(q, repo.Config)
+		case len(items) == 0:
+			// 元の商品が存在しない場合は商品を元にする方法では見つからないものとする
+			result = &domain.SearchResult{Items: []*domain.SearchItem{}}
+		case tier == domain.RecommendSimilar:
+			recommendQuery = createSimilarItems(items, hits, q, repo.Config)
+		case tier == domain.RecommendSameGender:
+			recommendQuery = createSameGenderItems(items, q, repo.Config)
+		default:
+			recommendQuery = createRecommendItems(items, q, repo.Config)
+		}
+		if recommendQuery != nil {
+			if result, err = repo.searchRecommendItems(ctx, recommendQuery, items, diversify, fit, bmi); err != nil {
+				return nil, err
+			}
+		}
+		result.Tier = tier
+		if result.Total > 0 {
+			break
+		}
+	}
+	return result, nil
 }
 
 // Classification function
-func (repo *ItemRepository) Classification(q map[string]string) (*elastic.SearchResult, error) {
-	query, err := createClassificationQuery(q)
+func (repo *ItemRepository) Classification(ctx context.Context, q map[string]string) (*domain.ClassificationResult, error) {
+	tree, err := classificationTree(q)
+	if err != nil {
+		return nil, err
+	}
+	_, source, err := classificationSource(q, repo.Config)
+	if err != nil {
+		return nil, err
+	}
+	if tree {
+		searchResult, err := repo.ElasticHandler.MultiSearch(ctx, createClassificationTreeQueries(q, source, repo.Config)...)
+		if err != nil {
+			return nil, err
+		}
+		return newClassificationTreeResult(searchResult)
+	}
+	query, err := createClassificationQuery(q, repo.Config)
+	if err != nil {
+		return nil, err
+	}
+	searchResult, err := repo.ElasticHandler.Search(ctx, query)
 	if err != nil {
 		return nil, err
 	}
-	return repo.ElasticHandler.Search(query)
+	return newClassificationResult(searchResult)
 }
 
-// AccessInfo function
-func (repo *ItemRepository) AccessInfo(q map[string]string) (*domain.Item, error) {
-	query := elastic.NewBoolQuery()
-	itemID, ok := q["item_id"]
-	if !ok {
-		return nil, fmt.Errorf("parameter not found")
+// Brand function
+func (repo *ItemRepository) Brand(ctx context.Context, q map[string]string) (*domain.BrandDetail, error) {
+	query, err := createBrandQuery(q, repo.Config)
+	if err != nil {
+		return nil, err
 	}
+	searchResult, err := repo.ElasticHandler.Search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	brands, err := newClassificationResult(searchResult)
+	if err != nil {
+		return nil, err
+	}
+	if len(brands.Hits) == 0 {
+		return nil, &domain.NotFoundError{Resource: "brand", ID: q["id"]}
+	}
+	searchResult, err = repo.ElasticHandler.Search(ctx, createBrandItemsQuery(brands.Hits[0], repo.Config))
+	if err != nil {
+		return nil, err
+	}
+	return newBrandDetail(brands.Hits[0], searchResult), nil
+}
 
-	query = query.Filter(elastic.NewTermQuery("item_id", itemID))
-
-	searchResult, err := repo.ElasticHandler.Search(&infrastructure.ElasticQuery{
-		Index: "items",
-		Query: query,
-		From:  0,
-		Size:  100,
-	})
+// Suggest function
+func (repo *ItemRepository) Suggest(ctx context.Context, q map[string]string) (*domain.Suggestions, error) {
+	queries, err := createSuggestQueries(q, repo.Config)
 	if err != nil {
 		return nil, err
 	}
+	searchResult, err := repo.ElasticHandler.MultiSearch(ctx, queries...)
+	if err != nil {
+		return nil, err
+	}
+	return newSuggestions(searchResult)
+}
+
+// accessCounterScript アクセス回数の加算と最終アクセス日時の更新をドキュメント単位で不可分に行う
+const accessCounterScript = `if (ctx._source.access_counter == null) { ctx._source.access_counter = params.count } else { ctx._source.access_counter += params.count } ctx._source.last_accessed_at = params.last_accessed_at`
+
+// accessSearchSize アクセスを記録する商品のドキュメントを検索する最大数
+const accessSearchSize = 10000
 
-	// 更新元の商品はIDを元に検索しているので複数個存在する場合がある、そのためアクセス回数の最も大きい値を更新元の数字として取得する
-	updateItem := &domain.Item{
-		AccessCounter:  0,
-		LastAccessedAt: time.Now(),
+func createAccessCounterScript(event *domain.AccessEvent) *elastic.Script {
+	return elastic.NewScript(accessCounterScript).
+		Lang("painless").
+		Param("count", event.Count).
+		Param("last_accessed_at", event.AccessedAt.Format(dateLayout))
+}
+
+// RecordAccess function
+func (repo *ItemRepository) RecordAccess(ctx context.Context, events []*domain.AccessEvent) error {
+	if len(events) == 0 {
+		return nil
 	}
-	var iType domain.Item
-	for _, item := range searchResult.Each(reflect.TypeOf(iType)) {
-		if i, ok := item.(domain.Item); ok {
-			if i.AccessCounter > updateItem.AccessCounter {
-				updateItem.AccessCounter = i.AccessCounter
-			}
-		}
+	scripts := make(map[string]*elastic.Script, len(events))
+	itemIDs := make([]string, 0, len(events))
+	for _, event := range events {
+		scripts[event.ItemID] = createAccessCounterScript(event)
+		itemIDs = append(itemIDs, event.ItemID)
+	}
+
+	// 更新元の商品はIDを元に検索しているので複数個存在する場合がある、そのため一致したドキュメントを全て更新する
+	searchResult, err := repo.ElasticHandler.Search(ctx, &infrastructure.ElasticQuery{
+		Index: repo.Config.Indices.Items,
+		Query: elastic.NewBoolQuery().Filter(newTermsString("item_id", itemIDs)),
+		From:  0,
+		Size:  accessSearchSize,
+	})
+	if err != nil {
+		return err
 	}
-	updateItem.AccessCounter++
 
+	var updates []*infrastructure.ElasticUpdate
 	for _, hit := range searchResult.Hits.Hits {
-		if _, err := repo.ElasticHandler.Update(hit, updateItem); err != nil {
-			return nil, err
+		item, err := newItem(hit)
+		if err != nil {
+			return err
 		}
+		if script, ok := scripts[item.ItemID]; ok {
+			updates = append(updates, &infrastructure.ElasticUpdate{Hit: hit, Script: script})
+		}
+	}
+	if len(updates) == 0 {
+		return nil
 	}
+	_, err = repo.ElasticHandler.BulkUpdate(ctx, updates)
+	return err
+}
 
-	return updateItem, nil
+// RecordAccessEvents function
+func (repo *ItemRepository) RecordAccessEvents(ctx context.Context, events []*domain.AccessEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	documents := make([]*infrastructure.ElasticDocument, 0, len(events))
+	for _, event := range events {
+		documents = append(documents, &infrastructure.ElasticDocument{Index: repo.Config.Indices.AccessEvents, Body: event})
+	}
+	_, err := repo.ElasticHandler.BulkIndex(ctx, documents)
+	return err
 }
@@ -2,14 +2,40 @@ package database
 
 import (
 	"encoding/json"
+	"math"
 	"testing"
+	"time"
 
 	"github.com/akaishi-sandbox/sam-go/domain"
+	"github.com/akaishi-sandbox/sam-go/infrastructure"
+	elastic "github.com/olivere/elastic/v7"
 )
 
+// testSearchConfig テストで使う検索の設定、起動時の既定値と同じ値にしている
+var testSearchConfig = SearchConfig{
+	Indices: Indices{
+		Items:        "items",
+		Categories:   "categories",
+		Brands:       "brands",
+		AccessEvents: "item_access_events",
+	},
+	DefaultLimit:        36,
+	ClassificationLimit: 100,
+	PopularityHalfLife:  30 * 24 * time.Hour,
+	TrendingHalfLife:    3 * 24 * time.Hour,
+	RecommendFallbacks:  []string{domain.RecommendSameGender, domain.RecommendPopular},
+	ClassificationSources: map[string]ClassificationSource{
+		"categories": {Filters: []string{"gender", "title", "parent_id"}},
+		"brands":     {Filters: []string{"gender", "title"}},
+	},
+}
+
 func TestCreateSearchQuery(t *testing.T) {
 	testCase := func(q map[string]string, ok string) {
-		query := createSearchQuery(q)
+		query, err := createSearchQuery(q, testSearchConfig)
+		if err != nil {
+			t.Errorf("createSearchQuery error:%v", err)
+		}
 
 		if query.Index != "items" {
 			t.Errorf("index error:%s", query.Index)
@@ -51,18 +77,34 @@ func TestCreateSearchQuery(t *testing.T) {
 		"min_price":       "100",
 		"max_price":       "10000",
 		"exclude_expired": "1",
-	}, `{"bool":{"filter":[{"terms":{"item_id":["123456AA"]}},{"terms":{"gender":["MEN"]}},{"terms":{"brand":["UNIQLO"]}},{"terms":{"category":["シャツ"]}},{"terms":{"discount_flag":["1"]}},{"range":{"lowest_price":{"from":100,"include_lower":true,"include_upper":true,"to":null}}},{"range":{"lowest_price":{"from":null,"include_lower":true,"include_upper":true,"to":10000}}}]}}`)
+	}, `{"bool":{"filter":[{"terms":{"item_id":["123456AA"]}},{"terms":{"gender":["MEN"]}},{"terms":{"brand":["UNIQLO"]}},{"terms":{"category":["シャツ"]}},{"terms":{"discount_flag":["1"]}},{"range":{"lowest_price":{"from":100,"include_lower":true,"include_upper":true,"to":null}}},{"range":{"lowest_price":{"from":null,"include_lower":true,"include_upper":true,"to":10000}}},{"terms":{"release_flag":[0,1]}}]}}`)
+	testCase(map[string]string{
+		"exclude_expired": "1",
+	}, `{"bool":{"filter":{"terms":{"release_flag":[0,1]}}}}`)
+	testCase(map[string]string{
+		"exclude_expired": "0",
+	}, `{"bool":{}}`)
 	testCase(map[string]string{
 		"keywords": "UNIQLO",
 	}, `{"bool":{"must":{"match_phrase":{"search_text":{"query":"UNIQLO"}}}}}`)
 	testCase(map[string]string{
 		"keywords": "UNIQLO シャツ",
 	}, `{"bool":{"must":[{"match_phrase":{"search_text":{"query":"UNIQLO"}}},{"match_phrase":{"search_text":{"query":"シャツ"}}}]}}`)
+	testCase(map[string]string{
+		"gender":   "MEN",
+		"keywords": "brand:UNIQLO -デニム",
+	}, `{"bool":{"filter":{"terms":{"gender":["MEN"]}},"must":{"term":{"brand":"UNIQLO"}},"must_not":{"match_phrase":{"search_text":{"query":"デニム"}}}}}`)
+
+	if _, err := createSearchQuery(map[string]string{
+		"keywords": `"UNIQLO`,
+	}, testSearchConfig); err == nil {
+		t.Errorf("malformed keywords accepted")
+	}
 }
 
 func TestCreateRecommendItems(t *testing.T) {
-	testCase := func(item domain.Item, q map[string]string, ok string) {
-		query := createRecommendItems(item, q)
+	testCase := func(items []*domain.Item, q map[string]string, ok string) {
+		query := createRecommendItems(items, q, testSearchConfig)
 
 		if query.Index != "items" {
 			t.Errorf("index error:%s", query.Index)
@@ -85,18 +127,214 @@ func TestCreateRecommendItems(t *testing.T) {
 		}
 	}
 
-	testCase(domain.Item{
+	testCase([]*domain.Item{{
 		ItemID:   "ABCDEF",
 		Gender:   "MEN",
 		Category: "シャツ",
-	}, map[string]string{
+	}}, map[string]string{
 		"item_id": "ABCDEF",
 	}, `{"bool":{"filter":[{"terms":{"gender":["MEN"]}},{"terms":{"category":["シャツ"]}}],"must_not":{"term":{"item_id":"ABCDEF"}}}}`)
+	testCase([]*domain.Item{
+		{ItemID: "ABCDEF", Gender: "MEN", Category: "シャツ"},
+		{ItemID: "ABCDEG", Gender: "MEN", Category: "シャツ"},
+	}, map[string]string{
+		"item_id": "ABCDEF,ABCDEG",
+	}, `{"bool":{"filter":[{"terms":{"gender":["MEN"]}},{"terms":{"category":["シャツ"]}}],"must_not":{"terms":{"item_id":["ABCDEF","ABCDEG"]}}}}`)
+	testCase([]*domain.Item{
+		{ItemID: "ABCDEF", Gender: "MEN", Category: "シャツ"},
+		{ItemID: "ABCDEG", Gender: "WOMEN", Category: "パンツ"},
+	}, map[string]string{
+		"item_id": "ABCDEF,ABCDEG",
+	}, `{"bool":{"filter":{"bool":{"should":[{"bool":{"filter":[{"terms":{"gender":["MEN"]}},{"terms":{"category":["シャツ"]}}]}},{"bool":{"filter":[{"terms":{"gender":["WOMEN"]}},{"terms":{"category":["パンツ"]}}]}}]}},"must_not":{"terms":{"item_id":["ABCDEF","ABCDEG"]}}}}`)
+}
+
+func TestCreateRecommendSourcesQuery(t *testing.T) {
+	query := createRecommendSourcesQuery([]string{"ABCDEF", "ABCDEG"}, testSearchConfig)
+	if query.Index != "items" || query.Size != 2 || query.Collapse == nil {
+		t.Fatalf("recommend sources query:%+v", query)
+	}
+	// 同じitem_idのドキュメントが複数あっても元の商品の数だけ取得できる
+	s, err := query.Collapse.Source()
+	if err != nil {
+		t.Errorf("collapse source:%v", err)
+	}
+	if j, _ := json.Marshal(s); string(j) != `{"field":"item_id"}` {
+		t.Errorf("collapse source:%s", j)
+	}
+}
+
+func TestCreateSimilarItems(t *testing.T) {
+	query := createSimilarItems([]*domain.Item{{
+		ItemID:      "ABCDEF",
+		LowestPrice: 5000,
+	}}, []*elastic.SearchHit{{Index: "items", Id: "1"}}, map[string]string{
+		"item_id":  "ABCDEF",
+		"strategy": "similar",
+	}, testSearchConfig)
+
+	s, err := query.Query.Source()
+	if err != nil {
+		t.Errorf("query source:%v", err)
+	}
+	j, err := json.Marshal(s)
+	if err != nil {
+		t.Errorf("query source not map string:%v", s)
+	}
+	if source := string(j); source != `{"function_score":{"boost_mode":"multiply","functions":[{"gauss":{"lowest_price":{"decay":0.5,"origin":5000,"scale":1500}}}],"query":{"bool":{"must":{"more_like_this":{"fields":["search_text","title","brand"],"like":[{"_id":"1","_index":"items"}],"max_query_terms":25,"min_doc_freq":1,"min_term_freq":1}},"must_not":{"term":{"item_id":"ABCDEF"}}}}}}` {
+		t.Errorf("query source:%s", source)
+	}
+	if len(query.Sort) != 0 {
+		t.Errorf("similar items sorted:%v", query.Sort)
+	}
+
+	if strategy, err := recommendStrategy(map[string]string{}); err != nil || strategy != domain.RecommendSameCategory {
+		t.Errorf("default strategy:%s %v", strategy, err)
+	}
+	if _, err := recommendStrategy(map[string]string{"strategy": "random"}); err == nil {
+		t.Errorf("unsupported strategy accepted")
+	}
+}
+
+func TestCreateRecommendFallbackItems(t *testing.T) {
+	testCase := func(query *infrastructure.ElasticQuery, ok string) {
+		s, err := query.Query.Source()
+		if err != nil {
+			t.Errorf("query source:%v", err)
+		}
+		j, err := json.Marshal(s)
+		if err != nil {
+			t.Errorf("query source not map string:%v", s)
+		}
+		if source := string(j); source != ok {
+			t.Errorf("query source:%s", source)
+		}
+	}
+	q := map[string]string{"item_id": "ABCDEF"}
+	testCase(createSameGenderItems([]*domain.Item{{Gender: "MEN"}, {Gender: "MEN,WOMEN"}}, q, testSearchConfig),
+		`{"bool":{"filter":{"terms":{"gender":["MEN","WOMEN"]}},"must_not":{"terms":{"item_id":["ABCDEF"]}}}}`)
+	popular := createPopularItems(q, testSearchConfig)
+	testCase(popular,
+		`{"function_score":{"boost_mode":"replace","functions":[{"field_value_factor":{"field":"access_counter","missing":0,"modifier":"log1p"}},{"exp":{"last_accessed_at":{"decay":0.5,"origin":"now","scale":"720h"}}}],"query":{"bool":{"must_not":{"terms":{"item_id":["ABCDEF"]}}}},"score_mode":"multiply"}}`)
+	if len(popular.Sort) != 2 {
+		t.Errorf("popular items sort:%v", popular.Sort)
+	}
+
+	if tiers, err := recommendFallbacks(map[string]string{}, testSearchConfig); err != nil || len(tiers) != 2 || tiers[0] != "same_gender" || tiers[1] != "popular" {
+		t.Errorf("default fallbacks:%v %v", tiers, err)
+	}
+	if tiers, err := recommendFallbacks(map[string]string{}, SearchConfig{}); err != nil || len(tiers) != 0 {
+		t.Errorf("configured fallbacks:%v %v", tiers, err)
+	}
+	if tiers, err := recommendFallbacks(map[string]string{"fallback": "popular"}, testSearchConfig); err != nil || len(tiers) != 1 || tiers[0] != "popular" {
+		t.Errorf("fallbacks:%v %v", tiers, err)
+	}
+	if tiers, err := recommendFallbacks(map[string]string{"fallback": "none"}, testSearchConfig); err != nil || len(tiers) != 0 {
+		t.Errorf("no fallbacks:%v %v", tiers, err)
+	}
+	if _, err := recommendFallbacks(map[string]string{"fallback": "similar"}, testSearchConfig); err == nil {
+		t.Errorf("unsupported fallback accepted")
+	}
+}
+
+func TestCreateCoViewQueries(t *testing.T) {
+	testCase := func(query *infrastructure.ElasticQuery, ok string) {
+		if query.Index != "item_access_events" || query.Size != 0 {
+			t.Errorf("query error:%s %d", query.Index, query.Size)
+		}
+		source, err := query.Query.Source()
+		if err != nil {
+			t.Errorf("query source:%v", err)
+		}
+		aggregations := make(map[string]interface{})
+		for name, aggregation := range query.Aggregations {
+			if aggregations[name], err = aggregation.Source(); err != nil {
+				t.Errorf("aggregation source:%v", err)
+			}
+		}
+		j, err := json.Marshal(map[string]interface{}{"query": source, "aggs": aggregations})
+		if err != nil {
+			t.Errorf("query source not map string:%v", source)
+		}
+		if source := string(j); source != ok {
+			t.Errorf("query source:%s", source)
+		}
+	}
+	testCase(createCoViewSessionsQuery([]string{"A001", "A002"}, testSearchConfig),
+		`{"aggs":{"sessions":{"terms":{"field":"session_id","size":1000}}},"query":{"bool":{"filter":{"terms":{"item_id":["A001","A002"]}}}}}`)
+	testCase(createCoViewItemsQuery([]string{"A001"}, []string{"S001", "S002"}, testSearchConfig),
+		`{"aggs":{"items":{"aggregations":{"sessions":{"cardinality":{"field":"session_id"}}},"terms":{"field":"item_id","order":[{"sessions":"desc"},{"_key":"asc"}],"size":1000}}},"query":{"bool":{"filter":{"terms":{"session_id":["S001","S002"]}},"must_not":{"terms":{"item_id":["A001"]}}}}}`)
+
+	// 集計した商品は他の方法と同じくbrandで絞り込み、同じitem_idのドキュメントを1件にまとめて取得する
+	query := createCoViewedItems([]string{"A002", "A003"}, map[string]string{"brand": "UNIQLO,GU"}, testSearchConfig)
+	if query.Index != "items" || query.Size != 2 || query.Collapse == nil {
+		t.Fatalf("co viewed items query:%+v", query)
+	}
+	source, err := query.Query.Source()
+	if err != nil {
+		t.Errorf("query source:%v", err)
+	}
+	if j, _ := json.Marshal(source); string(j) != `{"bool":{"filter":[{"terms":{"item_id":["A002","A003"]}},{"terms":{"brand":["UNIQLO","GU"]}}]}}` {
+		t.Errorf("co viewed items query source:%s", j)
+	}
+
+	if strategy, err := recommendStrategy(map[string]string{"strategy": "co_viewed"}); err != nil || strategy != domain.RecommendCoViewed {
+		t.Errorf("co viewed strategy:%s %v", strategy, err)
+	}
+}
+
+func TestCreateSizeFitQuery(t *testing.T) {
+	query := createRecommendItems([]*domain.Item{{
+		Gender:   "MEN",
+		Category: "シャツ",
+	}}, map[string]string{
+		"item_id": "ABCDEF",
+	}, testSearchConfig)
+	applySizeFit(query, 22)
+
+	s, err := query.Query.Source()
+	if err != nil {
+		t.Errorf("query source:%v", err)
+	}
+	j, err := json.Marshal(s)
+	if err != nil {
+		t.Errorf("query source not map string:%v", s)
+	}
+	if source := string(j); source != `{"bool":{"must":[{"bool":{"filter":[{"terms":{"gender":["MEN"]}},{"terms":{"category":["シャツ"]}}],"must_not":{"term":{"item_id":"ABCDEF"}}}},{"nested":{"inner_hits":{"name":"fit_sku","size":1},"path":"SKUs","query":{"function_score":{"boost_mode":"replace","functions":[{"gauss":{"SKUs.bmi":{"decay":0.5,"origin":22,"scale":1.5}}}],"query":{"bool":{"filter":[{"range":{"SKUs.stock":{"from":1,"include_lower":true,"include_upper":true,"to":null}}},{"range":{"SKUs.bmi":{"from":19,"include_lower":true,"include_upper":true,"to":25}}}]}}}},"score_mode":"max"}}]}}` {
+		t.Errorf("query source:%s", source)
+	}
+}
+
+func TestParseFitBmi(t *testing.T) {
+	testCase := func(q map[string]string, bmi float64, fit bool) {
+		v, ok, err := parseFitBmi(q)
+		if err != nil {
+			t.Errorf("parseFitBmi error:%v", err)
+		}
+		if ok != fit || math.Abs(v-bmi) > 0.01 {
+			t.Errorf("parseFitBmi:%v %v <> %v %v", v, ok, bmi, fit)
+		}
+	}
+	testCase(map[string]string{}, 0, false)
+	testCase(map[string]string{"bmi": "21.5"}, 21.5, true)
+	testCase(map[string]string{"height": "170", "weight": "65"}, 22.49, true)
+	testCase(map[string]string{"bmi": "20", "height": "170", "weight": "65"}, 20, true)
+
+	for _, q := range []map[string]string{
+		{"bmi": "abc"},
+		{"bmi": "-1"},
+		{"height": "170"},
+		{"weight": "65"},
+		{"height": "0", "weight": "65"},
+	} {
+		if _, _, err := parseFitBmi(q); err == nil {
+			t.Errorf("invalid parameter accepted:%v", q)
+		}
+	}
 }
 
 func TestCreateClassificationQuery(t *testing.T) {
 	testCase := func(q map[string]string, index, ok string) {
-		query, err := createClassificationQuery(q)
+		query, err := createClassificationQuery(q, testSearchConfig)
 		if err != nil {
 			t.Errorf("createClassificationQuery error:%v", err)
 		}
@@ -134,3 +372,404 @@ func TestCreateClassificationQuery(t *testing.T) {
 		"brands",
 		`{"bool":{"filter":{"terms":{"title":["UNIQLO"]}}}}`)
 }
+
+func TestClassificationSource(t *testing.T) {
+	config := SearchConfig{
+		ClassificationSources: map[string]ClassificationSource{
+			"colors":    {Filters: []string{"title"}, Sort: "title"},
+			"campaigns": {Index: "campaigns_v2", Filters: []string{"gender"}, Sort: "sort_no", Descending: true},
+		},
+	}
+	query, err := createClassificationQuery(map[string]string{"index": "colors", "title": "RED,BLUE", "limit": "10"}, config)
+	if err != nil {
+		t.Fatalf("createClassificationQuery error:%v", err)
+	}
+	s, err := query.Query.Source()
+	if err != nil {
+		t.Errorf("query source:%v", err)
+	}
+	if j, _ := json.Marshal(s); query.Index != "colors" || query.Size != 10 || string(j) != `{"bool":{"filter":{"terms":{"title":["RED","BLUE"]}}}}` {
+		t.Errorf("colors query:%s %d %s", query.Index, query.Size, j)
+	}
+	if s, _ := query.Sort[0].Source(); s.(map[string]interface{})["title"] == nil {
+		t.Errorf("colors sort:%v", s)
+	}
+
+	query, err = createClassificationQuery(map[string]string{"index": "campaigns", "gender": "WOMEN"}, config)
+	if err != nil {
+		t.Fatalf("createClassificationQuery error:%v", err)
+	}
+	if sort := query.Sort[0].(elastic.SortInfo); query.Index != "campaigns_v2" || sort.Field != "sort_no" || sort.Ascending {
+		t.Errorf("campaigns query:%s %+v", query.Index, sort)
+	}
+
+	if _, err := createClassificationQuery(map[string]string{"index": "colors", "gender": "MEN"}, config); err == nil || err.Error() != "invalid parameters: gender is not supported for colors" {
+		t.Errorf("unknown filter accepted:%v", err)
+	}
+	if _, err := createClassificationQuery(map[string]string{"index": "categories"}, config); err == nil {
+		t.Errorf("unregistered index accepted")
+	}
+	if _, err := createClassificationQuery(map[string]string{"index": "categories", "color": "RED"}, testSearchConfig); err == nil {
+		t.Errorf("unknown filter accepted")
+	}
+}
+
+func TestCreateBrandQueries(t *testing.T) {
+	query, err := createBrandQuery(map[string]string{"id": "12"}, testSearchConfig)
+	if err != nil {
+		t.Fatalf("createBrandQuery error:%v", err)
+	}
+	s, err := query.Query.Source()
+	if err != nil {
+		t.Errorf("query source:%v", err)
+	}
+	if j, _ := json.Marshal(s); query.Index != "brands" || query.Size != 1 || string(j) != `{"ids":{"values":["12"]}}` {
+		t.Errorf("brand query:%s %s", query.Index, j)
+	}
+	if _, err := createBrandQuery(map[string]string{}, testSearchConfig); err == nil {
+		t.Errorf("brand without id accepted")
+	}
+
+	query = createBrandItemsQuery(&domain.Classification{ID: "12", Title: "UNIQLO"}, testSearchConfig)
+	if s, err = query.Query.Source(); err != nil {
+		t.Errorf("query source:%v", err)
+	}
+	if j, _ := json.Marshal(s); query.Index != "items" || query.Size != 0 || !query.TrackTotalHits || string(j) != `{"bool":{"filter":{"term":{"brand":"UNIQLO"}}}}` {
+		t.Errorf("brand items query:%s %s", query.Index, j)
+	}
+	aggregations := make(map[string]interface{})
+	for name, aggregation := range query.Aggregations {
+		if aggregations[name], err = aggregation.Source(); err != nil {
+			t.Errorf("aggregation source:%v", err)
+		}
+	}
+	if j, _ := json.Marshal(aggregations); string(j) != `{"categories":{"terms":{"field":"category","size":100}},"discount":{"filter":{"term":{"discount_flag":1}}},"genders":{"terms":{"field":"gender","size":100}},"max_price":{"max":{"field":"lowest_price"}},"min_price":{"min":{"field":"lowest_price"}}}` {
+		t.Errorf("brand items aggregations:%s", j)
+	}
+}
+
+func TestSearchConfigIndices(t *testing.T) {
+	config := testSearchConfig
+	config.Indices.Items, config.Indices.Brands, config.DefaultLimit = "items_v2", "brands_v2", 24
+	query, err := createSearchQuery(map[string]string{}, config)
+	if err != nil {
+		t.Fatalf("createSearchQuery error:%v", err)
+	}
+	if query.Index != "items_v2" || query.Size != 24 {
+		t.Errorf("search query:%s %d", query.Index, query.Size)
+	}
+	if query, err = createBrandQuery(map[string]string{"id": "12"}, config); err != nil || query.Index != "brands_v2" {
+		t.Errorf("brand query:%+v %v", query, err)
+	}
+	if query = createCoViewSessionsQuery([]string{"1"}, config); query.Index != "item_access_events" {
+		t.Errorf("co-view sessions query:%s", query.Index)
+	}
+
+	config.ClassificationSources = map[string]ClassificationSource{"colors": {Filters: []string{"limit"}}}
+	config.RecommendFallbacks = []string{"random"}
+	if problems := config.Validate(); len(problems) != 2 {
+		t.Errorf("validate:%v", problems)
+	}
+	if problems := testSearchConfig.Validate(); len(problems) != 0 {
+		t.Errorf("default config invalid:%v", problems)
+	}
+}
+
+func TestCreateClassificationTreeQueries(t *testing.T) {
+	queries := createClassificationTreeQueries(map[string]string{"index": "categories", "gender": "MEN"}, ClassificationSource{Index: "categories", Sort: "title", Descending: true}, testSearchConfig)
+	if len(queries) != 2 || queries[0].Index != "categories" || queries[1].Index != "items" || queries[1].Size != 0 {
+		t.Fatalf("queries error:%+v", queries)
+	}
+	// 分類の設定の並び順を使う
+	if sort, ok := queries[0].Sort[0].(elastic.SortInfo); !ok || sort.Field != "title" || sort.Ascending {
+		t.Errorf("sort error:%v", queries[0].Sort)
+	}
+	for i, ok := range []string{
+		`{"bool":{"filter":{"terms":{"gender":["MEN"]}}}}`,
+		`{"bool":{"filter":{"terms":{"gender":["MEN"]}}}}`,
+	} {
+		s, err := queries[i].Query.Source()
+		if err != nil {
+			t.Errorf("query source:%v", err)
+		}
+		j, err := json.Marshal(s)
+		if err != nil {
+			t.Errorf("query source not map string:%v", s)
+		}
+		if source := string(j); source != ok {
+			t.Errorf("query source:%s", source)
+		}
+	}
+	s, err := queries[1].Aggregations["categories"].Source()
+	if err != nil {
+		t.Errorf("aggregation source:%v", err)
+	}
+	if j, _ := json.Marshal(s); string(j) != `{"terms":{"field":"category","size":1000}}` {
+		t.Errorf("aggregation source:%s", j)
+	}
+
+	if tree, err := classificationTree(map[string]string{"index": "categories", "mode": "tree"}); err != nil || !tree {
+		t.Errorf("tree mode:%v %v", tree, err)
+	}
+	if tree, err := classificationTree(map[string]string{"index": "brands"}); err != nil || tree {
+		t.Errorf("list mode:%v %v", tree, err)
+	}
+	if _, err := classificationTree(map[string]string{"index": "brands", "mode": "tree"}); err == nil {
+		t.Errorf("brands tree accepted")
+	}
+	if _, err := classificationTree(map[string]string{"index": "categories", "mode": "graph"}); err == nil {
+		t.Errorf("unsupported mode accepted")
+	}
+}
+
+func TestCreateSearchQueryFacets(t *testing.T) {
+	toJSON := func(s interface{}, err error) string {
+		if err != nil {
+			t.Errorf("source:%v", err)
+		}
+		j, err := json.Marshal(s)
+		if err != nil {
+			t.Errorf("source not map string:%v", s)
+		}
+		return string(j)
+	}
+
+	query, err := createSearchQuery(map[string]string{
+		"item_id": "123456AA",
+		"brand":   "UNIQLO",
+		"gender":  "MEN",
+		"facets":  "brand,price,unknown",
+	}, testSearchConfig)
+	if err != nil {
+		t.Fatalf("createSearchQuery error:%v", err)
+	}
+	if source := toJSON(query.Query.Source()); source != `{"bool":{"filter":{"terms":{"item_id":["123456AA"]}}}}` {
+		t.Errorf("query source:%s", source)
+	}
+	if query.PostFilter == nil {
+		t.Fatalf("post filter nil")
+	}
+	if source := toJSON(query.PostFilter.Source()); source != `{"bool":{"filter":[{"terms":{"gender":["MEN"]}},{"terms":{"brand":["UNIQLO"]}}]}}` {
+		t.Errorf("post filter source:%s", source)
+	}
+	if len(query.Aggregations) != 2 {
+		t.Fatalf("aggregations:%v", query.Aggregations)
+	}
+	if source := toJSON(query.Aggregations["brand"].Source()); source != `{"aggregations":{"brand":{"terms":{"field":"brand","size":100}}},"filter":{"bool":{"filter":{"terms":{"gender":["MEN"]}}}}}` {
+		t.Errorf("brand aggregation source:%s", source)
+	}
+	if source := toJSON(query.Aggregations["price"].Source()); source != `{"aggregations":{"price":{"range":{"field":"lowest_price","ranges":[{"to":1000},{"from":1000,"to":3000},{"from":3000,"to":5000},{"from":5000,"to":10000},{"from":10000,"to":20000},{"from":20000}]}}},"filter":{"bool":{"filter":[{"terms":{"gender":["MEN"]}},{"terms":{"brand":["UNIQLO"]}}]}}}` {
+		t.Errorf("price aggregation source:%s", source)
+	}
+
+	query, err = createSearchQuery(map[string]string{
+		"brand": "UNIQLO",
+	}, testSearchConfig)
+	if err != nil {
+		t.Fatalf("createSearchQuery error:%v", err)
+	}
+	if query.PostFilter != nil || query.Aggregations != nil {
+		t.Errorf("facets not requested:%v %v", query.PostFilter, query.Aggregations)
+	}
+}
+
+func TestCreateSearchQueryCursor(t *testing.T) {
+	cursor := domain.Cursor{Order: "new", After: []interface{}{json.Number("1583020800000"), "123456AA"}}.String()
+
+	query, err := createSearchQuery(map[string]string{
+		"offset": "72",
+		"cursor": cursor,
+	}, testSearchConfig)
+	if err != nil {
+		t.Fatalf("createSearchQuery error:%v", err)
+	}
+	if query.From != 0 {
+		t.Errorf("from not reset:%d", query.From)
+	}
+	j, err := json.Marshal(query.SearchAfter)
+	if err != nil {
+		t.Errorf("search after not marshal:%v", query.SearchAfter)
+	}
+	if source := string(j); source != `[1583020800000,"123456AA"]` {
+		t.Errorf("search after:%s", source)
+	}
+	if len(query.Sort) != 2 {
+		t.Errorf("sort without tiebreaker:%v", query.Sort)
+	}
+
+	if _, err := createSearchQuery(map[string]string{
+		"cursor": "!!invalid!!",
+	}, testSearchConfig); err == nil {
+		t.Errorf("invalid cursor accepted")
+	}
+	// 新着順のカーソルは価格順の検索に使えない
+	if _, err := createSearchQuery(map[string]string{
+		"order":  "min-max",
+		"cursor": cursor,
+	}, testSearchConfig); err == nil {
+		t.Errorf("cursor of another order accepted")
+	}
+}
+
+func TestNormalizeKeywords(t *testing.T) {
+	testCase := func(keywords string, ok []string) {
+		words := normalizeKeywords(keywords)
+		if len(words) != len(ok) {
+			t.Errorf("normalize error:%q <> %q", words, ok)
+			return
+		}
+		for i := range words {
+			if words[i] != ok[i] {
+				t.Errorf("normalize error:%q <> %q", words, ok)
+			}
+		}
+	}
+
+	testCase("UNIQLO シャツ", []string{"UNIQLO", "シャツ"})
+	testCase("ＵＮＩＱＬＯ　ｼｬﾂ", []string{"UNIQLO", "シャツ"})
+	testCase("ﾃﾞﾆﾑ ﾊﾟﾝﾂ", []string{"デニム", "パンツ"})
+	testCase("  デニム　　パンツ ", []string{"デニム", "パンツ"})
+	testCase("　", []string{})
+}
+
+func TestCreateSuggestQueries(t *testing.T) {
+	queries, err := createSuggestQueries(map[string]string{
+		"keywords": "ｕｎｉ",
+		"gender":   "MEN",
+	}, testSearchConfig)
+	if err != nil {
+		t.Fatalf("createSuggestQueries error:%v", err)
+	}
+	if len(queries) != 3 || queries[0].Index != "brands" || queries[1].Index != "categories" || queries[2].Index != "items" {
+		t.Fatalf("suggest indexes error:%v", queries)
+	}
+	s, err := queries[0].Query.Source()
+	if err != nil {
+		t.Errorf("query source:%v", err)
+	}
+	j, err := json.Marshal(s)
+	if err != nil {
+		t.Errorf("query source not map string:%v", s)
+	}
+	if source := string(j); source != `{"bool":{"filter":[{"match":{"title.suggest":{"operator":"and","query":"uni"}}},{"terms":{"gender":["MEN"]}}]}}` {
+		t.Errorf("brands query source:%s", source)
+	}
+	s, err = queries[2].Aggregations["keywords"].Source()
+	if err != nil {
+		t.Errorf("aggregation source:%v", err)
+	}
+	j, err = json.Marshal(s)
+	if err != nil {
+		t.Errorf("aggregation source not map string:%v", s)
+	}
+	if source := string(j); source != `{"aggregations":{"values":{"terms":{"field":"title.keyword","size":10}}},"filter":{"match":{"title.suggest":{"operator":"and","query":"uni"}}}}` {
+		t.Errorf("keywords aggregation source:%s", source)
+	}
+
+	// 半角カナは濁点を合成して全角に揃える
+	queries, err = createSuggestQueries(map[string]string{"keywords": "ﾃﾞﾆﾑ"}, testSearchConfig)
+	if err != nil {
+		t.Fatalf("createSuggestQueries error:%v", err)
+	}
+	s, _ = queries[2].Aggregations["brands"].Source()
+	if j, _ = json.Marshal(s); string(j) != `{"aggregations":{"values":{"terms":{"field":"brand","size":100}}},"filter":{"match":{"brand.suggest":{"operator":"and","query":"デニム"}}}}` {
+		t.Errorf("brands aggregation source:%s", j)
+	}
+
+	if _, err := createSuggestQueries(map[string]string{"keywords": "　"}, testSearchConfig); err == nil {
+		t.Errorf("empty keywords accepted")
+	}
+}
+
+func TestCreateSearchQueryHighlight(t *testing.T) {
+	query, err := createSearchQuery(map[string]string{
+		"keywords":  "シャツ",
+		"highlight": "1",
+	}, testSearchConfig)
+	if err != nil {
+		t.Fatalf("createSearchQuery error:%v", err)
+	}
+	if query.Highlight == nil {
+		t.Fatalf("highlight nil")
+	}
+	s, err := query.Highlight.Source()
+	if err != nil {
+		t.Errorf("highlight source:%v", err)
+	}
+	j, err := json.Marshal(s)
+	if err != nil {
+		t.Errorf("highlight source not map string:%v", s)
+	}
+	if source := string(j); source != `{"encoder":"html","fields":{"description":{},"search_text":{},"title":{}},"fragment_size":100,"number_of_fragments":3,"post_tags":["\u003c/em\u003e"],"pre_tags":["\u003cem\u003e"]}` {
+		t.Errorf("highlight source:%s", source)
+	}
+
+	query, err = createSearchQuery(map[string]string{
+		"keywords": "シャツ",
+	}, testSearchConfig)
+	if err != nil {
+		t.Fatalf("createSearchQuery error:%v", err)
+	}
+	if query.Highlight != nil {
+		t.Errorf("highlight not requested:%v", query.Highlight)
+	}
+}
+
+func TestCreateAccessCounterScript(t *testing.T) {
+	script := createAccessCounterScript(&domain.AccessEvent{
+		ItemID:     "123456AA",
+		Count:      3,
+		AccessedAt: time.Date(2020, 3, 1, 9, 0, 0, 0, time.UTC),
+	})
+	s, err := script.Source()
+	if err != nil {
+		t.Errorf("script source:%v", err)
+	}
+	j, err := json.Marshal(s)
+	if err != nil {
+		t.Errorf("script source not map string:%v", s)
+	}
+	if source := string(j); source != `{"lang":"painless","params":{"count":3,"last_accessed_at":"2020-03-01T09:00:00.000Z"},"source":"if (ctx._source.access_counter == null) { ctx._source.access_counter = params.count } else { ctx._source.access_counter += params.count } ctx._source.last_accessed_at = params.last_accessed_at"}` {
+		t.Errorf("script source:%s", source)
+	}
+}
+
+func TestCreateSearchQueryPopular(t *testing.T) {
+	testCase := func(order, ok string) {
+		// 続きのページはカーソルの日時を基準にスコアを求める
+		query, err := createSearchQuery(map[string]string{
+			"gender": "MEN",
+			"order":  order,
+			"cursor": domain.Cursor{Order: order, After: []interface{}{1.5, "A001"}, Origin: 1583020800000}.String(),
+		}, SearchConfig{DefaultLimit: 36, PopularityHalfLife: 7 * 24 * time.Hour, TrendingHalfLife: 36 * time.Hour})
+		if err != nil {
+			t.Fatalf("createSearchQuery error:%v", err)
+		}
+		s, err := query.Query.Source()
+		if err != nil {
+			t.Errorf("query source:%v", err)
+		}
+		j, err := json.Marshal(s)
+		if err != nil {
+			t.Errorf("query source not map string:%v", s)
+		}
+		if source := string(j); source != ok {
+			t.Errorf("query source:%s <> %s", source, ok)
+		}
+		if sort, ok := query.Sort[0].(elastic.SortInfo); !ok || sort.Field != "_score" || sort.Ascending {
+			t.Errorf("sort error:%v", query.Sort)
+		}
+	}
+
+	testCase("popular", `{"function_score":{"boost_mode":"replace","functions":[{"field_value_factor":{"field":"access_counter","missing":0,"modifier":"log1p"}},{"exp":{"last_accessed_at":{"decay":0.5,"origin":"2020-03-01T00:00:00.000Z","scale":"168h"}}}],"query":{"bool":{"filter":{"terms":{"gender":["MEN"]}}}},"score_mode":"multiply"}}`)
+	testCase("trending", `{"function_score":{"boost_mode":"replace","functions":[{"field_value_factor":{"field":"access_counter","missing":0,"modifier":"log1p"}},{"exp":{"last_accessed_at":{"decay":0.5,"origin":"2020-03-01T00:00:00.000Z","scale":"36h"}}}],"query":{"bool":{"filter":{"terms":{"gender":["MEN"]}}}},"score_mode":"multiply"}}`)
+
+	// 最初のページは検索した時点を基準とし、カーソルで引き継ぐ
+	condition, err := parseSearchCondition(map[string]string{"order": "popular"}, testSearchConfig)
+	if err != nil || time.Since(condition.origin) > time.Minute {
+		t.Errorf("popular origin error:%v %v", condition, err)
+	}
+	if condition, err := parseSearchCondition(map[string]string{"order": "new"}, testSearchConfig); err != nil || !condition.origin.IsZero() {
+		t.Errorf("new origin error:%v %v", condition, err)
+	}
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"strings"
+
+	"github.com/akaishi-sandbox/sam-go/domain"
+	elastic "github.com/olivere/elastic/v7"
+)
+
+// keywordQuery キーワードの構文木をElasticsearchの検索条件に変換する
+func keywordQuery(node domain.KeywordNode) elastic.Query {
+	switch n := node.(type) {
+	case *domain.KeywordTerm:
+		if n.Field != "" {
+			return elastic.NewTermQuery(n.Field, n.Text)
+		}
+		return elastic.NewMatchPhraseQuery("search_text", n.Text)
+	case *domain.KeywordAnd:
+		return applyKeywords(elastic.NewBoolQuery(), n)
+	case *domain.KeywordOr:
+		query := elastic.NewBoolQuery()
+		for _, child := range n.Children {
+			query = query.Should(keywordQuery(child))
+		}
+		return query
+	case *domain.KeywordNot:
+		return elastic.NewBoolQuery().MustNot(keywordQuery(n.Child))
+	default:
+		return elastic.NewMatchNoneQuery()
+	}
+}
+
+// applyKeywords ANDの条件を検索条件に追加する、除外の条件はmust_notとする
+func applyKeywords(query *elastic.BoolQuery, and *domain.KeywordAnd) *elastic.BoolQuery {
+	for _, child := range and.Children {
+		if not, ok := child.(*domain.KeywordNot); ok {
+			query = query.MustNot(keywordQuery(not.Child))
+		} else {
+			query = query.Must(keywordQuery(child))
+		}
+	}
+	return query
+}
+
+// matchKeywords メモリ上の商品に対してキーワードの構文木を評価する
+func matchKeywords(node domain.KeywordNode, item *domain.Item) bool {
+	switch n := node.(type) {
+	case *domain.KeywordTerm:
+		if n.Field != "" {
+			return itemField(item, n.Field) == n.Text
+		}
+		return strings.Contains(strings.ToLower(itemSearchText(item)), strings.ToLower(n.Text))
+	case *domain.KeywordAnd:
+		for _, child := range n.Children {
+			if !matchKeywords(child, item) {
+				return false
+			}
+		}
+		return true
+	case *domain.KeywordOr:
+		for _, child := range n.Children {
+			if matchKeywords(child, item) {
+				return true
+			}
+		}
+		return false
+	case *domain.KeywordNot:
+		return !matchKeywords(n.Child, item)
+	default:
+		return false
+	}
+}
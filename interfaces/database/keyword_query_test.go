@@ -0,0 +1,47 @@
+package database
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/akaishi-sandbox/sam-go/domain"
+)
+
+func TestKeywordQuery(t *testing.T) {
+	tests := []struct {
+		keywords string
+		ok       string
+	}{
+		{`UNIQLO`, `{"bool":{"must":{"match_phrase":{"search_text":{"query":"UNIQLO"}}}}}`},
+		{`UNIQLO　シャツ`, `{"bool":{"must":[{"match_phrase":{"search_text":{"query":"UNIQLO"}}},{"match_phrase":{"search_text":{"query":"シャツ"}}}]}}`},
+		{`シャツ/ブラウス`, `{"bool":{"must":{"bool":{"should":[{"match_phrase":{"search_text":{"query":"シャツ"}}},{"match_phrase":{"search_text":{"query":"ブラウス"}}}]}}}}`},
+		{`パンツ -デニム`, `{"bool":{"must":{"match_phrase":{"search_text":{"query":"パンツ"}}},"must_not":{"match_phrase":{"search_text":{"query":"デニム"}}}}}`},
+		{`T-シャツ`, `{"bool":{"must":{"match_phrase":{"search_text":{"query":"T-シャツ"}}}}}`},
+		{`"THE NORTH  FACE"`, `{"bool":{"must":{"match_phrase":{"search_text":{"query":"THE NORTH FACE"}}}}}`},
+		{`brand:UNIQLO category:シャツ`, `{"bool":{"must":[{"term":{"brand":"UNIQLO"}},{"term":{"category":"シャツ"}}]}}`},
+		{`brand:"THE NORTH FACE"`, `{"bool":{"must":{"term":{"brand":"THE NORTH FACE"}}}}`},
+		{`ｂｒａｎｄ：ＵＮＩＱＬＯ`, `{"bool":{"must":{"term":{"brand":"UNIQLO"}}}}`},
+		{`ﾃﾞﾆﾑ -ﾊﾟﾝﾂ`, `{"bool":{"must":{"match_phrase":{"search_text":{"query":"デニム"}}},"must_not":{"match_phrase":{"search_text":{"query":"パンツ"}}}}}`},
+		{`size:M`, `{"bool":{"must":{"match_phrase":{"search_text":{"query":"size:M"}}}}}`},
+		{`（シャツ/ブラウス） -(白 長袖)`, `{"bool":{"must":{"bool":{"should":[{"match_phrase":{"search_text":{"query":"シャツ"}}},{"match_phrase":{"search_text":{"query":"ブラウス"}}}]}},"must_not":{"bool":{"must":[{"match_phrase":{"search_text":{"query":"白"}}},{"match_phrase":{"search_text":{"query":"長袖"}}}]}}}}`},
+		{`シャツ/-白`, `{"bool":{"must":{"bool":{"should":[{"match_phrase":{"search_text":{"query":"シャツ"}}},{"bool":{"must_not":{"match_phrase":{"search_text":{"query":"白"}}}}}]}}}}`},
+	}
+	for _, test := range tests {
+		and, err := domain.ParseKeywords(test.keywords)
+		if err != nil {
+			t.Errorf("ParseKeywords(%q) error:%v", test.keywords, err)
+			continue
+		}
+		s, err := keywordQuery(and).Source()
+		if err != nil {
+			t.Errorf("query source:%v", err)
+		}
+		j, err := json.Marshal(s)
+		if err != nil {
+			t.Errorf("query source not map string:%v", s)
+		}
+		if source := string(j); source != test.ok {
+			t.Errorf("keywordQuery(%q):%s <> %s", test.keywords, source, test.ok)
+		}
+	}
+}
@@ -0,0 +1,868 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akaishi-sandbox/sam-go/domain"
+	elastic "github.com/olivere/elastic/v7"
+)
+
+// MemoryItemRepository メモリ上の商品を対象にElasticsearchと同じ条件で検索する、テストやローカルでの動作確認に使う
+type MemoryItemRepository struct {
+	Items           []*domain.Item
+	Classifications map[string][]*domain.Classification
+	Config          SearchConfig
+	AccessEvents    []*domain.AccessEvent
+	mutex           sync.Mutex
+}
+
+// NewMemoryItemRepository instance
+func NewMemoryItemRepository(items []*domain.Item, classifications map[string][]*domain.Classification, config SearchConfig) *MemoryItemRepository {
+	return &MemoryItemRepository{
+		Items:           items,
+		Classifications: classifications,
+		Config:          config,
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func itemField(item *domain.Item, field string) string {
+	switch field {
+	case "item_id":
+		return item.ItemID
+	case "gender":
+		return item.Gender
+	case "brand":
+		return item.Brand
+	case "category":
+		return item.Category
+	case "discount_flag":
+		return strconv.Itoa(item.DiscountFlag)
+	default:
+		return ""
+	}
+}
+
+// itemSearchText Elasticsearchのsearch_textに相当する文字列
+func itemSearchText(item *domain.Item) string {
+	return strings.Join([]string{item.Title, item.Brand, item.Category, item.Description}, " ")
+}
+
+// sortValue 並び順の値、人気順の場合はcreatePopularityQueryと同じ計算でスコアを求める
+func (condition *searchCondition) sortValue(item *domain.Item, now time.Time) float64 {
+	switch condition.sort.Field {
+	case "lowest_price":
+		return float64(item.LowestPrice)
+	case "_score":
+		score := math.Log1p(float64(item.AccessCounter))
+		if !item.LastAccessedAt.IsZero() {
+			score *= math.Pow(0.5, math.Abs(float64(now.Sub(item.LastAccessedAt)))/float64(condition.halfLife))
+		}
+		return score
+	default:
+		return float64(item.UpdatedAt.UnixNano() / int64(time.Millisecond))
+	}
+}
+
+func cursorValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// match excludeで指定した項目の条件を除いて商品が条件に一致するか評価する
+func (condition *searchCondition) match(item *domain.Item, exclude string) bool {
+	if len(condition.itemIDs) > 0 && !containsString(condition.itemIDs, item.ItemID) {
+		return false
+	}
+	for _, filter := range condition.filters {
+		if filter.name != exclude && !filter.match(item) {
+			return false
+		}
+	}
+	if condition.minBmi != nil || condition.maxBmi != nil {
+		found := false
+		for _, sku := range item.SKUs {
+			if (condition.minBmi == nil || sku.Bmi >= *condition.minBmi) &&
+				(condition.maxBmi == nil || sku.Bmi <= *condition.maxBmi) &&
+				sku.Stock >= 1 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if condition.keywords != nil && !matchKeywords(condition.keywords, item) {
+		return false
+	}
+	if condition.excludeExpired && item.ReleaseFlag != 0 && item.ReleaseFlag != 1 {
+		return false
+	}
+	return true
+}
+
+// less 並び順の値が同じ場合はitem_idの昇順とする
+func (condition *searchCondition) less(value float64, itemID string, otherValue float64, otherItemID string) bool {
+	if value != otherValue {
+		if condition.sort.Ascending {
+			return value < otherValue
+		}
+		return value > otherValue
+	}
+	return itemID < otherItemID
+}
+
+func (condition *searchCondition) after(item *domain.Item, now time.Time) bool {
+	if len(condition.cursor.After) != 2 {
+		return true
+	}
+	value, ok := cursorValue(condition.cursor.After[0])
+	itemID, idOk := condition.cursor.After[1].(string)
+	if !ok || !idOk {
+		return true
+	}
+	return condition.less(value, itemID, condition.sortValue(item, now), item.ItemID)
+}
+
+func (condition *searchCondition) newCursor(item *domain.Item, now time.Time) string {
+	cursor := domain.Cursor{Order: condition.order, Origin: originMillis(condition.origin)}
+	switch condition.sort.Field {
+	case "lowest_price":
+		cursor.After = []interface{}{item.LowestPrice, item.ItemID}
+	case "_score":
+		cursor.After = []interface{}{condition.sortValue(item, now), item.ItemID}
+	default:
+		cursor.After = []interface{}{item.UpdatedAt.UnixNano() / int64(time.Millisecond), item.ItemID}
+	}
+	return cursor.String()
+}
+
+func paginate(items []*domain.Item, from, size int) []*domain.Item {
+	if from < 0 {
+		from = 0
+	}
+	if from >= len(items) || size <= 0 {
+		return nil
+	}
+	if from+size > len(items) {
+		return items[from:]
+	}
+	return items[from : from+size]
+}
+
+func reorderItems(items []*domain.Item, order []int) []*domain.Item {
+	reordered := make([]*domain.Item, 0, len(order))
+	for _, i := range order {
+		reordered = append(reordered, items[i])
+	}
+	return reordered
+}
+
+func newTermsBuckets(counts map[string]int64) []domain.FacetBucket {
+	buckets := []domain.FacetBucket{}
+	for key, count := range counts {
+		buckets = append(buckets, domain.FacetBucket{Key: key, Count: count})
+	}
+	// Elasticsearchのterms集計と同じく件数の降順、同じ件数の場合は値の昇順とする
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].Count != buckets[j].Count {
+			return buckets[i].Count > buckets[j].Count
+		}
+		return buckets[i].Key < buckets[j].Key
+	})
+	if len(buckets) > facetSize {
+		buckets = buckets[:facetSize]
+	}
+	return buckets
+}
+
+func newPriceBuckets(items []*domain.Item) []domain.FacetBucket {
+	buckets := []domain.FacetBucket{}
+	for _, r := range priceRanges {
+		bucket := domain.FacetBucket{}
+		from, to := "*", "*"
+		if r[0] != 0 {
+			value := r[0]
+			bucket.From = &value
+			from = strconv.FormatFloat(value, 'f', 1, 64)
+		}
+		if r[1] != 0 {
+			value := r[1]
+			bucket.To = &value
+			to = strconv.FormatFloat(value, 'f', 1, 64)
+		}
+		bucket.Key = from + "-" + to
+		for _, item := range items {
+			price := float64(item.LowestPrice)
+			if (bucket.From == nil || price >= *bucket.From) && (bucket.To == nil || price < *bucket.To) {
+				bucket.Count++
+			}
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets
+}
+
+func (repo *MemoryItemRepository) facets(condition *searchCondition) *domain.Facets {
+	facets := &domain.Facets{}
+	for _, name := range condition.facets {
+		var items []*domain.Item
+		for _, item := range repo.Items {
+			if condition.match(item, name) {
+				items = append(items, item)
+			}
+		}
+		if name == domain.FacetPrice {
+			facets.Price = newPriceBuckets(items)
+			continue
+		}
+		counts := make(map[string]int64)
+		for _, item := range items {
+			counts[itemField(item, name)]++
+		}
+		switch name {
+		case domain.FacetBrand:
+			facets.Brand = newTermsBuckets(counts)
+		case domain.FacetCategory:
+			facets.Category = newTermsBuckets(counts)
+		case domain.FacetGender:
+			facets.Gender = newTermsBuckets(counts)
+		case domain.FacetDiscountFlag:
+			facets.DiscountFlag = newTermsBuckets(counts)
+		}
+	}
+	return facets
+}
+
+func newMemorySearchResult(total int, items []*domain.Item) *domain.SearchResult {
+	result := &domain.SearchResult{
+		Total: int64(total),
+		Items: []*domain.SearchItem{},
+	}
+	for _, item := range items {
+		copied := *item
+		result.Items = append(result.Items, &domain.SearchItem{Item: &copied})
+	}
+	return result
+}
+
+// Search function
+func (repo *MemoryItemRepository) Search(ctx context.Context, q map[string]string) (*domain.SearchResult, error) {
+	condition, err := parseSearchCondition(q, repo.Config)
+	if err != nil {
+		return nil, err
+	}
+	diversify, err := parseDiversify(q, repo.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	// 人気順・急上昇順はcreatePopularityQueryと同じくカーソルから引き継いだ日時を基準とする
+	now := condition.origin
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	var hits []*domain.Item
+	for _, item := range repo.Items {
+		if condition.match(item, "") {
+			hits = append(hits, item)
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		return condition.less(condition.sortValue(hits[i], now), hits[i].ItemID, condition.sortValue(hits[j], now), hits[j].ItemID)
+	})
+	total := len(hits)
+	if len(condition.cursor.After) > 0 {
+		var after []*domain.Item
+		for _, item := range hits {
+			if condition.after(item, now) {
+				after = append(after, item)
+			}
+		}
+		hits = after
+	}
+
+	if diversify != nil {
+		hits = paginate(hits, 0, diversifyWindow)
+		hits = reorderItems(hits, diversify.rerank(hits, condition.size))
+		total = int(windowTotal(int64(total)))
+	}
+	page := paginate(hits, condition.from, condition.size)
+	result := newMemorySearchResult(total, page)
+	// 最後のページでは続きがないためカーソルを返却しない
+	if len(page) > 0 && len(page) >= condition.size && diversify == nil {
+		result.Cursor = condition.newCursor(page[len(page)-1], now)
+	}
+	if len(condition.facets) > 0 {
+		result.Facets = repo.facets(condition)
+	}
+	return result, nil
+}
+
+// similarity createSimilarItemsの代わりに共通する語の数と価格の近さでスコアを求める
+func similarity(source, item *domain.Item) float64 {
+	words := make(map[string]bool)
+	for _, word := range strings.Fields(strings.ToLower(itemSearchText(source))) {
+		words[word] = true
+	}
+	matched := 0
+	for _, word := range strings.Fields(strings.ToLower(itemSearchText(item))) {
+		if words[word] {
+			matched++
+			delete(words, word)
+		}
+	}
+	if matched == 0 {
+		return 0
+	}
+	distance := float64(item.LowestPrice-source.LowestPrice) / similarPriceDistance(source.LowestPrice)
+	return float64(matched) * math.Pow(0.5, distance*distance)
+}
+
+// fitSKU createSizeFitQueryの代わりに在庫のあるSKUのうちBMIが最も近いものとそのスコアを求める
+func fitSKU(item *domain.Item, bmi float64) (*domain.SKU, float64) {
+	var best *domain.SKU
+	score := 0.0
+	for i := range item.SKUs {
+		sku := &item.SKUs[i]
+		if sku.Stock < 1 || math.Abs(sku.Bmi-bmi) > sizeFitBmiRange {
+			continue
+		}
+		distance := (sku.Bmi - bmi) / sizeFitBmiScale
+		if s := math.Pow(0.5, distance*distance); best == nil || s > score {
+			best, score = sku, s
+		}
+	}
+	return best, score
+}
+
+// recommendCoViewed createCoViewItemsQueryと同じく閲覧したセッション数の多い順、同じ場合は商品IDの順に候補の件数まで選び、brandと体型で絞り込む
+func (repo *MemoryItemRepository) recommendCoViewed(itemIDs []string, diversify *diversifyCondition, q map[string]string, fit bool, bmi float64) *domain.SearchResult {
+	sessions := make(map[string]bool)
+	for _, event := range repo.AccessEvents {
+		if containsString(itemIDs, event.ItemID) {
+			sessions[event.SessionID] = true
+		}
+	}
+	coViewed := make(map[string]map[string]bool)
+	for _, event := range repo.AccessEvents {
+		if !sessions[event.SessionID] || containsString(itemIDs, event.ItemID) {
+			continue
+		}
+		if coViewed[event.ItemID] == nil {
+			coViewed[event.ItemID] = make(map[string]bool)
+		}
+		coViewed[event.ItemID][event.SessionID] = true
+	}
+	ranked := make([]string, 0, len(coViewed))
+	for itemID := range coViewed {
+		ranked = append(ranked, itemID)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ci, cj := len(coViewed[ranked[i]]), len(coViewed[ranked[j]]); ci != cj {
+			return ci > cj
+		}
+		return ranked[i] < ranked[j]
+	})
+	if len(ranked) > coViewItemSize {
+		ranked = ranked[:coViewItemSize]
+	}
+	ranks := make(map[string]int, len(ranked))
+	for i, itemID := range ranked {
+		ranks[itemID] = i
+	}
+
+	var hits []*domain.Item
+	fitSKUs := make(map[string]*domain.SKU)
+	collapsed := make(map[string]bool)
+	for _, item := range repo.Items {
+		// 同じitem_idの商品は1件にまとめる
+		if _, ok := ranks[item.ItemID]; !ok || collapsed[item.ItemID] {
+			continue
+		}
+		if brand, ok := q["brand"]; ok && !containsString(strings.Split(brand, ","), item.Brand) {
+			continue
+		}
+		if fit {
+			sku, _ := fitSKU(item, bmi)
+			if sku == nil {
+				continue
+			}
+			fitSKUs[item.ItemID] = sku
+		}
+		collapsed[item.ItemID] = true
+		hits = append(hits, item)
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		return ranks[hits[i].ItemID] < ranks[hits[j].ItemID]
+	})
+	from, size := parsePaging(q, repo.Config.DefaultLimit)
+	total := len(hits)
+	if diversify != nil {
+		total = int(windowTotal(int64(total)))
+	}
+	hits = reorderItems(hits, diversify.rerank(hits, size))
+	result := newMemorySearchResult(total, paginate(hits, from, size))
+	for _, item := range result.Items {
+		if sku, ok := fitSKUs[item.ItemID]; ok {
+			copied := *sku
+			item.FitSKU = &copied
+		}
+	}
+	return result
+}
+
+// recommendTier 元の商品を除いてtierの方法でおすすめ商品を選ぶ、人気順の場合はsourcesが空でもよい
+func (repo *MemoryItemRepository) recommendTier(tier string, sources []*domain.Item, itemIDs []string, diversify *diversifyCondition, q map[string]string, fit bool, bmi float64) *domain.SearchResult {
+	popularity := &searchCondition{sort: elastic.SortInfo{Field: "_score"}, halfLife: repo.Config.PopularityHalfLife}
+	now := time.Now()
+
+	var hits []*domain.Item
+	scores := make(map[string]float64)
+	fitSKUs := make(map[string]*domain.SKU)
+	for _, item := range repo.Items {
+		if containsString(itemIDs, item.ItemID) {
+			continue
+		}
+		if brand, ok := q["brand"]; ok && !containsString(strings.Split(brand, ","), item.Brand) {
+			continue
+		}
+		switch tier {
+		case domain.RecommendSimilar:
+			for _, source := range sources {
+				scores[item.ItemID] = math.Max(scores[item.ItemID], similarity(source, item))
+			}
+			if scores[item.ItemID] == 0 {
+				continue
+			}
+		case domain.RecommendPopular:
+			scores[item.ItemID] = popularity.sortValue(item, now)
+		case domain.RecommendSameGender:
+			if seedIndex(item, sources) < 0 {
+				continue
+			}
+		default:
+			if seed := seedIndex(item, sources); seed < 0 || sources[seed].Category != item.Category {
+				continue
+			}
+		}
+		if fit {
+			sku, score := fitSKU(item, bmi)
+			if sku == nil {
+				continue
+			}
+			fitSKUs[item.ItemID] = sku
+			scores[item.ItemID] += score
+		}
+		hits = append(hits, item)
+	}
+	if tier == domain.RecommendSimilar || tier == domain.RecommendPopular || fit {
+		sort.SliceStable(hits, func(i, j int) bool {
+			if si, sj := scores[hits[i].ItemID], scores[hits[j].ItemID]; si != sj || tier != domain.RecommendPopular {
+				return si > sj
+			}
+			return hits[i].ItemID < hits[j].ItemID
+		})
+	}
+	from, size := parsePaging(q, repo.Config.DefaultLimit)
+	// searchRecommendItemsと同じく固定の件数の候補を並べ替える
+	if len(sources) > 1 || diversify != nil {
+		hits = paginate(hits, 0, diversifyWindow)
+	}
+	if len(sources) > 1 {
+		hits = reorderItems(hits, diversifyBySeed(hits, sources))
+	}
+	hits = reorderItems(hits, diversify.rerank(hits, size))
+	result := newMemorySearchResult(len(hits), paginate(hits, from, size))
+	for _, item := range result.Items {
+		if sku, ok := fitSKUs[item.ItemID]; ok {
+			copied := *sku
+			item.FitSKU = &copied
+		}
+	}
+	return result
+}
+
+// Recommend function
+func (repo *MemoryItemRepository) Recommend(ctx context.Context, q map[string]string) (*domain.SearchResult, error) {
+	itemID, ok := q["item_id"]
+	if !ok {
+		return nil, domain.NewValidationError("item_id", "is required")
+	}
+	strategy, err := recommendStrategy(q)
+	if err != nil {
+		return nil, err
+	}
+	fallbacks, err := recommendFallbacks(q, repo.Config)
+	if err != nil {
+		return nil, err
+	}
+	bmi, fit, err := parseFitBmi(q)
+	if err != nil {
+		return nil, err
+	}
+	diversify, err := parseDiversify(q, repo.Config)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateSeedWindow(q, repo.Config); err != nil {
+		return nil, err
+	}
+	strict := q["strict"] == "1"
+
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	itemIDs := strings.Split(itemID, ",")
+	var sources []*domain.Item
+	for _, id := range itemIDs {
+		for _, item := range repo.Items {
+			if item.ItemID == id {
+				sources = append(sources, item)
+				break
+			}
+		}
+	}
+	if len(sources) == 0 && strict {
+		return nil, &domain.NotFoundError{Resource: "item", ID: itemID}
+	}
+
+	tiers := []string{strategy}
+	if !strict {
+		tiers = append(tiers, fallbacks...)
+	}
+	var result *domain.SearchResult
+	for _, tier := range tiers {
+		switch {
+		case tier == domain.RecommendCoViewed:
+			result = repo.recommendCoViewed(itemIDs, diversify, q, fit, bmi)
+		case tier == domain.RecommendPopular || len(sources) > 0:
+			result = repo.recommendTier(tier, sources, itemIDs, diversify, q, fit, bmi)
+		default:
+			result = &domain.SearchResult{Items: []*domain.SearchItem{}}
+		}
+		result.Tier = tier
+		if result.Total > 0 {
+			break
+		}
+	}
+	return result, nil
+}
+
+// classificationField 分類の項目の値、domain.Classificationにない項目はfalseを返却する
+func classificationField(classification *domain.Classification, field string) (string, bool) {
+	switch field {
+	case "id":
+		return classification.ID, true
+	case "title":
+		return classification.Title, true
+	case "gender":
+		return classification.Gender, true
+	case "parent_id":
+		return classification.ParentID, true
+	case "sort_no":
+		return strconv.Itoa(classification.SortNo), true
+	default:
+		return "", false
+	}
+}
+
+// validateClassificationFields メモリ上の分類はdomain.Classificationの項目しか持たないため、それ以外の項目の絞り込みや並び順はエラーとする
+func validateClassificationFields(name string, source ClassificationSource, q map[string]string) error {
+	for _, filter := range source.Filters {
+		if _, ok := q[filter]; !ok {
+			continue
+		}
+		if _, ok := classificationField(&domain.Classification{}, filter); !ok {
+			return domain.NewValidationError(filter, "is not supported for "+name)
+		}
+	}
+	if _, ok := classificationField(&domain.Classification{}, source.Sort); !ok {
+		return fmt.Errorf("classification sort %s is not supported for %s", source.Sort, name)
+	}
+	return nil
+}
+
+// classifications createClassificationQueryと同じく設定された項目で絞り込み、設定された項目で並べる
+func (repo *MemoryItemRepository) classifications(name string, source ClassificationSource, q map[string]string) []*domain.Classification {
+	var hits []*domain.Classification
+	for _, classification := range repo.Classifications[name] {
+		matched := true
+		for _, filter := range source.Filters {
+			if value, ok := q[filter]; ok {
+				field, _ := classificationField(classification, filter)
+				if !containsString(strings.Split(value, ","), field) {
+					matched = false
+				}
+			}
+		}
+		if matched {
+			hits = append(hits, classification)
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if source.Sort == "" || source.Sort == "sort_no" {
+			if source.Descending {
+				return hits[i].SortNo > hits[j].SortNo
+			}
+			return hits[i].SortNo < hits[j].SortNo
+		}
+		vi, _ := classificationField(hits[i], source.Sort)
+		vj, _ := classificationField(hits[j], source.Sort)
+		if source.Descending {
+			return vi > vj
+		}
+		return vi < vj
+	})
+	return hits
+}
+
+// classificationTree createClassificationTreeQueriesと同じくgenderのみで絞り込み、設定された項目で並べたカテゴリを返却する
+func (repo *MemoryItemRepository) classificationTree(name string, source ClassificationSource, q map[string]string) *domain.ClassificationResult {
+	source.Filters = []string{"gender"}
+	hits := repo.classifications(name, source, q)
+	counts := make(map[string]int64)
+	for _, item := range repo.Items {
+		if gender, ok := q["gender"]; ok && !containsString(strings.Split(gender, ","), item.Gender) {
+			continue
+		}
+		counts[item.Category]++
+	}
+	result := &domain.ClassificationResult{
+		Total: int64(len(hits)),
+		Hits:  []*domain.Classification{},
+	}
+	for _, hit := range hits {
+		copied := *hit
+		result.Hits = append(result.Hits, &copied)
+	}
+	result.Tree = newClassificationTree(result.Hits, counts)
+	return result
+}
+
+// Classification function
+func (repo *MemoryItemRepository) Classification(ctx context.Context, q map[string]string) (*domain.ClassificationResult, error) {
+	name, source, err := classificationSource(q, repo.Config)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateClassificationFields(name, source, q); err != nil {
+		return nil, err
+	}
+
+	tree, err := classificationTree(q)
+	if err != nil {
+		return nil, err
+	}
+
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if tree {
+		return repo.classificationTree(name, source, q), nil
+	}
+	hits := repo.classifications(name, source, q)
+	result := &domain.ClassificationResult{
+		Total: int64(len(hits)),
+		Hits:  []*domain.Classification{},
+	}
+	from, size := parsePaging(q, repo.Config.ClassificationLimit)
+	if from < 0 {
+		from = 0
+	}
+	for i := from; i < len(hits) && i < from+size; i++ {
+		copied := *hits[i]
+		result.Hits = append(result.Hits, &copied)
+	}
+	return result, nil
+}
+
+// suggestMatch 入力の全ての語がvalueのいずれかの語の先頭に一致する、ElasticsearchのnewSuggestQueryと同じ判定
+func suggestMatch(value, text string) bool {
+	words := normalizeKeywords(strings.ToLower(value))
+	for _, prefix := range strings.Fields(strings.ToLower(text)) {
+		matched := false
+		for _, word := range words {
+			if strings.HasPrefix(word, prefix) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func (repo *MemoryItemRepository) classificationSuggestions(index, text string, size int, q map[string]string, count func(*domain.Item, string) bool) []domain.Suggestion {
+	suggestions := []domain.Suggestion{}
+	for _, classification := range repo.classifications(index, ClassificationSource{}, map[string]string{}) {
+		if len(suggestions) >= size {
+			break
+		}
+		if gender, ok := q["gender"]; ok && len(gender) > 0 && !containsString(strings.Split(gender, ","), classification.Gender) {
+			continue
+		}
+		if !suggestMatch(classification.Title, text) {
+			continue
+		}
+		suggestion := domain.Suggestion{Text: classification.Title}
+		for _, item := range repo.Items {
+			if gender, ok := q["gender"]; ok && len(gender) > 0 && !containsString(strings.Split(gender, ","), item.Gender) {
+				continue
+			}
+			if count(item, classification.Title) {
+				suggestion.Count++
+			}
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions
+}
+
+// Suggest function
+func (repo *MemoryItemRepository) Suggest(ctx context.Context, q map[string]string) (*domain.Suggestions, error) {
+	text, size, err := parseSuggestText(q)
+	if err != nil {
+		return nil, err
+	}
+
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	suggestions := &domain.Suggestions{
+		Keywords: []domain.Suggestion{},
+	}
+	titles := make(map[string]int64)
+	for _, item := range repo.Items {
+		if gender, ok := q["gender"]; ok && len(gender) > 0 && !containsString(strings.Split(gender, ","), item.Gender) {
+			continue
+		}
+		if suggestMatch(item.Title, text) {
+			titles[item.Title]++
+		}
+	}
+	for i, bucket := range newTermsBuckets(titles) {
+		if i >= size {
+			break
+		}
+		suggestions.Keywords = append(suggestions.Keywords, domain.Suggestion{Text: bucket.Key, Count: bucket.Count})
+	}
+	suggestions.Brands = repo.classificationSuggestions("brands", text, size, q, func(item *domain.Item, title string) bool {
+		return item.Brand == title
+	})
+	suggestions.Categories = repo.classificationSuggestions("categories", text, size, q, func(item *domain.Item, title string) bool {
+		return item.Category == title
+	})
+	return suggestions, nil
+}
+
+// Brand function
+func (repo *MemoryItemRepository) Brand(ctx context.Context, q map[string]string) (*domain.BrandDetail, error) {
+	id, ok := q["id"]
+	if !ok || len(id) == 0 {
+		return nil, domain.NewValidationError("id", "is required")
+	}
+
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	var brand *domain.Classification
+	for _, classification := range repo.Classifications["brands"] {
+		if classification.ID == id {
+			copied := *classification
+			brand = &copied
+			break
+		}
+	}
+	if brand == nil {
+		return nil, &domain.NotFoundError{Resource: "brand", ID: id}
+	}
+
+	detail := &domain.BrandDetail{Brand: brand}
+	categories := make(map[string]int64)
+	genders := make(map[string]int64)
+	discounts := 0
+	for _, item := range repo.Items {
+		if item.Brand != brand.Title {
+			continue
+		}
+		detail.ItemCount++
+		if detail.MinPrice == nil || item.LowestPrice < *detail.MinPrice {
+			price := item.LowestPrice
+			detail.MinPrice = &price
+		}
+		if detail.MaxPrice == nil || item.LowestPrice > *detail.MaxPrice {
+			price := item.LowestPrice
+			detail.MaxPrice = &price
+		}
+		categories[item.Category]++
+		genders[item.Gender]++
+		if item.DiscountFlag == 1 {
+			discounts++
+		}
+	}
+	detail.Categories = newTermsBuckets(categories)
+	detail.Genders = newTermsBuckets(genders)
+	if detail.ItemCount > 0 {
+		detail.DiscountShare = float64(discounts) / float64(detail.ItemCount)
+	}
+	return detail, nil
+}
+
+// RecordAccess function
+func (repo *MemoryItemRepository) RecordAccess(ctx context.Context, events []*domain.AccessEvent) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	for _, event := range events {
+		for _, item := range repo.Items {
+			if item.ItemID == event.ItemID {
+				item.AccessCounter += event.Count
+				item.LastAccessedAt = event.AccessedAt
+			}
+		}
+	}
+	return nil
+}
+
+// RecordAccessEvents function
+func (repo *MemoryItemRepository) RecordAccessEvents(ctx context.Context, events []*domain.AccessEvent) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	for _, event := range events {
+		copied := *event
+		repo.AccessEvents = append(repo.AccessEvents, &copied)
+	}
+	return nil
+}
@@ -0,0 +1,510 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akaishi-sandbox/sam-go/domain"
+	"github.com/akaishi-sandbox/sam-go/interfaces/database"
+	"github.com/akaishi-sandbox/sam-go/interfaces/database/databasetest"
+)
+
+func itemIDs(result *domain.SearchResult) []string {
+	ids := []string{}
+	for _, item := range result.Items {
+		ids = append(ids, item.ItemID)
+	}
+	return ids
+}
+
+func TestMemoryItemRepositorySearch(t *testing.T) {
+	repo := databasetest.NewItemRepository()
+	testCase := func(q map[string]string, total int64, ok []string) {
+		result, err := repo.Search(context.Background(), q)
+		if err != nil {
+			t.Errorf("search error:%v", err)
+			return
+		}
+		ids := itemIDs(result)
+		if result.Total != total || len(ids) != len(ok) {
+			t.Errorf("search %v:%d %v <> %d %v", q, result.Total, ids, total, ok)
+			return
+		}
+		for i := range ids {
+			if ids[i] != ok[i] {
+				t.Errorf("search %v:%v <> %v", q, ids, ok)
+			}
+		}
+	}
+
+	testCase(map[string]string{}, 4, []string{"A004", "A003", "A002", "A001"})
+	testCase(map[string]string{"gender": "MEN", "category": "シャツ"}, 2, []string{"A002", "A001"})
+	testCase(map[string]string{"min_price": "2000", "max_price": "3000"}, 1, []string{"A001"})
+	testCase(map[string]string{"min_bmi": "20", "max_bmi": "26"}, 1, []string{"A001"})
+	testCase(map[string]string{"keywords": "シャツ -デニム"}, 2, []string{"A004", "A001"})
+	testCase(map[string]string{"keywords": "brand:GU"}, 2, []string{"A004", "A003"})
+	testCase(map[string]string{"order": "min-max"}, 4, []string{"A004", "A003", "A001", "A002"})
+	testCase(map[string]string{"order": "max-max", "offset": "1", "limit": "2"}, 4, []string{"A001", "A003"})
+
+	// 販売終了の商品はexclude_expiredを指定した場合のみ除く
+	repo.Items[1].ReleaseFlag = 2
+	testCase(map[string]string{"exclude_expired": "1"}, 3, []string{"A004", "A003", "A001"})
+	testCase(map[string]string{}, 4, []string{"A004", "A003", "A002", "A001"})
+	repo.Items[1].ReleaseFlag = 0
+
+	now := time.Now()
+	repo.Items[0].AccessCounter, repo.Items[0].LastAccessedAt = 100, now.Add(-60*24*time.Hour)
+	repo.Items[1].AccessCounter, repo.Items[1].LastAccessedAt = 10, now
+	repo.Items[2].AccessCounter, repo.Items[2].LastAccessedAt = 5, now
+	testCase(map[string]string{"order": "popular", "limit": "3"}, 4, []string{"A002", "A003", "A001"})
+	// 続きのページは最初のページと同じ日時を基準にスコアを求める
+	popular, err := repo.Search(context.Background(), map[string]string{"order": "popular", "limit": "2"})
+	if err != nil {
+		t.Fatalf("search error:%v", err)
+	}
+	testCase(map[string]string{"order": "popular", "limit": "2", "cursor": popular.Cursor}, 4, []string{"A001", "A004"})
+	if cursor, err := domain.ParseCursor(popular.Cursor); err != nil || cursor.Origin == 0 {
+		t.Errorf("popular cursor origin:%+v %v", cursor, err)
+	}
+
+	first, err := repo.Search(context.Background(), map[string]string{"order": "min-max", "limit": "2"})
+	if err != nil {
+		t.Fatalf("search error:%v", err)
+	}
+	testCase(map[string]string{"order": "min-max", "limit": "2", "cursor": first.Cursor}, 4, []string{"A001", "A002"})
+	last, err := repo.Search(context.Background(), map[string]string{"order": "min-max", "limit": "3", "cursor": first.Cursor})
+	if err != nil {
+		t.Fatalf("search error:%v", err)
+	}
+	if ids := itemIDs(last); len(ids) != 2 || last.Cursor != "" {
+		t.Errorf("last page cursor:%v %s", ids, last.Cursor)
+	}
+	if _, err := repo.Search(context.Background(), map[string]string{"order": "new", "limit": "2", "cursor": first.Cursor}); err == nil {
+		t.Errorf("cursor of another order accepted")
+	}
+
+	if _, err := repo.Search(context.Background(), map[string]string{"keywords": "(シャツ"}); err == nil {
+		t.Errorf("malformed keywords accepted")
+	}
+}
+
+func TestMemoryItemRepositoryFacets(t *testing.T) {
+	repo := databasetest.NewItemRepository()
+	result, err := repo.Search(context.Background(), map[string]string{
+		"brand":  "UNIQLO",
+		"facets": "brand,price",
+	})
+	if err != nil {
+		t.Fatalf("search error:%v", err)
+	}
+	if result.Total != 2 {
+		t.Errorf("total error:%d", result.Total)
+	}
+	// ブランドを選択してもブランドの件数は他のブランドを含めて返却する
+	if len(result.Facets.Brand) != 2 || result.Facets.Brand[0].Key != "GU" || result.Facets.Brand[0].Count != 2 {
+		t.Errorf("brand facets error:%+v", result.Facets.Brand)
+	}
+	var count int64
+	for _, bucket := range result.Facets.Price {
+		count += bucket.Count
+	}
+	if count != 2 {
+		t.Errorf("price facets error:%+v", result.Facets.Price)
+	}
+}
+
+func TestMemoryItemRepositoryRecommend(t *testing.T) {
+	repo := databasetest.NewItemRepository()
+	result, err := repo.Recommend(context.Background(), map[string]string{"item_id": "A001"})
+	if err != nil {
+		t.Fatalf("recommend error:%v", err)
+	}
+	if ids := itemIDs(result); len(ids) != 1 || ids[0] != "A002" {
+		t.Errorf("recommend error:%v", ids)
+	}
+	result, err = repo.Recommend(context.Background(), map[string]string{"item_id": "A001", "strategy": "similar"})
+	if err != nil {
+		t.Fatalf("recommend error:%v", err)
+	}
+	if ids := itemIDs(result); len(ids) != 2 || ids[0] != "A002" || ids[1] != "A004" {
+		t.Errorf("similar recommend error:%v", ids)
+	}
+	if result.Tier != "similar" {
+		t.Errorf("tier error:%s", result.Tier)
+	}
+}
+
+func TestMemoryItemRepositoryDiversify(t *testing.T) {
+	repo := databasetest.NewItemRepository()
+	result, err := repo.Search(context.Background(), map[string]string{"diversify": "brand", "diversify_limit": "1", "limit": "2"})
+	if err != nil {
+		t.Fatalf("search error:%v", err)
+	}
+	// 更新日時の降順はA004, A003(GU), A002, A001(UNIQLO)
+	if ids := itemIDs(result); result.Total != 4 || len(ids) != 2 || ids[0] != "A004" || ids[1] != "A002" || result.Cursor != "" {
+		t.Errorf("diversify search error:%d %v %s", result.Total, ids, result.Cursor)
+	}
+	result, err = repo.Search(context.Background(), map[string]string{"diversify": "brand", "diversify_limit": "1", "limit": "2", "offset": "2"})
+	if err != nil {
+		t.Fatalf("search error:%v", err)
+	}
+	if ids := itemIDs(result); len(ids) != 2 || ids[0] != "A003" || ids[1] != "A001" {
+		t.Errorf("diversify search second page error:%v", ids)
+	}
+
+	repo.Items = append(repo.Items, &domain.Item{ItemID: "A005", Brand: "UNIQLO", Gender: "MEN", Category: "シャツ"})
+	result, err = repo.Recommend(context.Background(), map[string]string{"item_id": "A001", "diversify": "brand", "diversify_limit": "1", "limit": "1", "offset": "1"})
+	if err != nil {
+		t.Fatalf("recommend error:%v", err)
+	}
+	if ids := itemIDs(result); result.Total != 2 || len(ids) != 1 || ids[0] != "A005" {
+		t.Errorf("diversify recommend error:%d %v", result.Total, ids)
+	}
+	if _, err := repo.Search(context.Background(), map[string]string{"diversify": "shop"}); err == nil {
+		t.Errorf("unsupported diversify accepted")
+	}
+	_, err = repo.Search(context.Background(), map[string]string{"diversify": "brand", "offset": "360"})
+	if validationErr, ok := err.(*domain.ValidationError); !ok || validationErr.Fields[0].Field != "offset" {
+		t.Errorf("diversify beyond window error:%v", err)
+	}
+
+	// 件数は取得できるページの範囲までとする
+	for i := 0; i < 400; i++ {
+		repo.Items = append(repo.Items, &domain.Item{ItemID: fmt.Sprintf("B%03d", i), Brand: "GU", Gender: "WOMEN", Category: "ブラウス"})
+	}
+	result, err = repo.Search(context.Background(), map[string]string{"diversify": "brand"})
+	if err != nil {
+		t.Fatalf("search error:%v", err)
+	}
+	if result.Total != 360 {
+		t.Errorf("diversify search total error:%d", result.Total)
+	}
+	if result, err := repo.Search(context.Background(), map[string]string{}); err != nil || result.Total != 405 {
+		t.Errorf("search total error:%v %v", result, err)
+	}
+}
+
+func TestMemoryItemRepositoryRecommendMultipleSeeds(t *testing.T) {
+	repo := databasetest.NewItemRepository()
+	repo.Items = append(repo.Items,
+		&domain.Item{ItemID: "A005", Title: "チノパンツ", Brand: "GU", Gender: "MEN", Category: "パンツ"},
+		&domain.Item{ItemID: "A006", Title: "カーゴパンツ", Brand: "GU", Gender: "MEN", Category: "パンツ"},
+		&domain.Item{ItemID: "A007", Title: "リネンシャツ", Brand: "GU", Gender: "MEN", Category: "シャツ"},
+	)
+	testCase := func(q map[string]string, ok []string) {
+		result, err := repo.Recommend(context.Background(), q)
+		if err != nil {
+			t.Fatalf("recommend error:%v", err)
+		}
+		ids := itemIDs(result)
+		if result.Total != 4 || len(ids) != len(ok) {
+			t.Errorf("multiple seeds error:%d %v <> %v", result.Total, ids, ok)
+			return
+		}
+		for i := range ok {
+			if ids[i] != ok[i] {
+				t.Errorf("multiple seeds error:%v <> %v", ids, ok)
+				return
+			}
+		}
+	}
+	// 元の商品毎に交互に並べる
+	testCase(map[string]string{"item_id": "A001,A003"}, []string{"A002", "A005", "A007", "A006"})
+	testCase(map[string]string{"item_id": "A001,A003", "offset": "1", "limit": "2"}, []string{"A005", "A007"})
+	// 存在しない元の商品は無視する
+	testCase(map[string]string{"item_id": "UNKNOWN,A001,A003"}, []string{"A002", "A005", "A007", "A006"})
+
+	// ページ毎に候補が変わらないため、続けて取得したページをつなげると1度に取得した結果と同じになる
+	var pages []string
+	for offset := 0; offset < 4; offset += 3 {
+		result, err := repo.Recommend(context.Background(), map[string]string{"item_id": "A001,A003", "offset": strconv.Itoa(offset), "limit": "3"})
+		if err != nil {
+			t.Fatalf("recommend error:%v", err)
+		}
+		pages = append(pages, itemIDs(result)...)
+	}
+	if ok := "A002 A005 A007 A006"; strings.Join(pages, " ") != ok {
+		t.Errorf("multiple seeds pages error:%v <> %s", pages, ok)
+	}
+	if _, err := repo.Recommend(context.Background(), map[string]string{"item_id": "A001,A003", "offset": "360"}); err == nil {
+		t.Errorf("multiple seeds beyond window accepted")
+	}
+}
+
+func TestMemoryItemRepositoryRecommendFallback(t *testing.T) {
+	repo := databasetest.NewItemRepository()
+	repo.Items[1].AccessCounter = 10
+	repo.Items[3].AccessCounter = 5
+	testCase := func(q map[string]string, tier string, ok []string) {
+		result, err := repo.Recommend(context.Background(), q)
+		if err != nil {
+			t.Fatalf("recommend error:%v", err)
+		}
+		ids := itemIDs(result)
+		if result.Tier != tier || len(ids) != len(ok) {
+			t.Errorf("fallback error:%s %v <> %s %v", result.Tier, ids, tier, ok)
+			return
+		}
+		for i := range ok {
+			if ids[i] != ok[i] {
+				t.Errorf("fallback error:%v <> %v", ids, ok)
+				return
+			}
+		}
+	}
+	testCase(map[string]string{"item_id": "A001"}, "same_category", []string{"A002"})
+	// 同じカテゴリの商品がないため同じ性別の商品を使う
+	testCase(map[string]string{"item_id": "A003"}, "same_gender", []string{"A001", "A002"})
+	// 元の商品が存在しないため人気順の商品を使う
+	testCase(map[string]string{"item_id": "UNKNOWN"}, "popular", []string{"A002", "A004", "A001", "A003"})
+	testCase(map[string]string{"item_id": "A003", "fallback": "popular"}, "popular", []string{"A002", "A004", "A001"})
+	testCase(map[string]string{"item_id": "A003", "fallback": "none"}, "same_category", []string{})
+
+	_, err := repo.Recommend(context.Background(), map[string]string{"item_id": "UNKNOWN", "strict": "1"})
+	if notFound, ok := err.(*domain.NotFoundError); !ok || notFound.ID != "UNKNOWN" {
+		t.Errorf("strict error:%v", err)
+	}
+	// 商品を元にする方法しかない場合も存在しない元の商品はエラーとせず、空の結果を返却する
+	testCase(map[string]string{"item_id": "UNKNOWN", "fallback": "same_gender"}, "same_gender", []string{})
+	testCase(map[string]string{"item_id": "UNKNOWN", "fallback": "none"}, "same_category", []string{})
+	if _, err := repo.Recommend(context.Background(), map[string]string{"item_id": "A001", "fallback": "random"}); err == nil {
+		t.Errorf("unsupported fallback accepted")
+	}
+}
+
+func TestMemoryItemRepositoryRecommendSizeFit(t *testing.T) {
+	repo := databasetest.NewItemRepository()
+	repo.Items = append(repo.Items,
+		&domain.Item{ItemID: "A005", Title: "リネンシャツ", Brand: "GU", Gender: "MEN", Category: "シャツ", SKUs: []domain.SKU{{Size: "S", Bmi: 19, Stock: 2}, {Size: "M", Bmi: 22.5, Stock: 1}, {Size: "L", Bmi: 25, Stock: 5}}},
+		&domain.Item{ItemID: "A006", Title: "ネルシャツ", Brand: "GU", Gender: "MEN", Category: "シャツ", SKUs: []domain.SKU{{Size: "L", Bmi: 24.5, Stock: 1}}},
+	)
+	result, err := repo.Recommend(context.Background(), map[string]string{"item_id": "A001", "bmi": "23"})
+	if err != nil {
+		t.Fatalf("recommend error:%v", err)
+	}
+	// A002は在庫がないため除外される
+	if ids := itemIDs(result); len(ids) != 2 || ids[0] != "A005" || ids[1] != "A006" {
+		t.Errorf("size fit recommend error:%v", ids)
+	}
+	if sku := result.Items[0].FitSKU; sku == nil || sku.Size != "M" {
+		t.Errorf("fit sku error:%+v", sku)
+	}
+	if result.Total != 2 {
+		t.Errorf("size fit total error:%d", result.Total)
+	}
+
+	result, err = repo.Recommend(context.Background(), map[string]string{"item_id": "A001", "height": "160", "weight": "64"})
+	if err != nil {
+		t.Fatalf("recommend error:%v", err)
+	}
+	if ids := itemIDs(result); len(ids) != 2 || ids[0] != "A005" || result.Items[0].FitSKU.Size != "L" {
+		t.Errorf("size fit recommend by height and weight error:%v", ids)
+	}
+	if _, err := repo.Recommend(context.Background(), map[string]string{"item_id": "A001", "bmi": "abc"}); err == nil {
+		t.Errorf("invalid bmi accepted")
+	}
+}
+
+func TestMemoryItemRepositoryRecommendCoViewed(t *testing.T) {
+	repo := databasetest.NewItemRepository()
+	accessedAt := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	for _, event := range [][2]string{
+		{"A001", "S001"}, {"A003", "S001"}, {"A004", "S001"},
+		{"A001", "S002"}, {"A004", "S002"}, {"A004", "S002"},
+		{"A002", "S003"}, {"A003", "S003"},
+		{"A003", "S004"},
+	} {
+		if err := repo.RecordAccessEvents(context.Background(), []*domain.AccessEvent{{ItemID: event[0], Count: 1, AccessedAt: accessedAt, SessionID: event[1]}}); err != nil {
+			t.Fatalf("record error:%v", err)
+		}
+	}
+	testCase := func(q map[string]string, total int64, ok []string) {
+		q["strategy"] = "co_viewed"
+		result, err := repo.Recommend(context.Background(), q)
+		if err != nil {
+			t.Fatalf("recommend error:%v", err)
+		}
+		ids := itemIDs(result)
+		if result.Total != total || len(ids) != len(ok) {
+			t.Errorf("co viewed error:%v %d <> %v %d", ids, result.Total, ok, total)
+			return
+		}
+		for i := range ok {
+			if ids[i] != ok[i] {
+				t.Errorf("co viewed error:%v <> %v", ids, ok)
+				return
+			}
+		}
+	}
+	// 同じセッションで閲覧された回数ではなくセッション数で並べる
+	testCase(map[string]string{"item_id": "A001"}, 2, []string{"A004", "A003"})
+	testCase(map[string]string{"item_id": "A001,A002"}, 2, []string{"A003", "A004"})
+	testCase(map[string]string{"item_id": "A001", "limit": "1", "offset": "1"}, 2, []string{"A003"})
+	testCase(map[string]string{"item_id": "A005", "fallback": "none"}, 0, []string{})
+	// 他の方法と同じくbrandと体型で絞り込み、件数は絞り込んだ商品の数とする
+	testCase(map[string]string{"item_id": "A003"}, 3, []string{"A001", "A002", "A004"})
+	testCase(map[string]string{"item_id": "A003", "brand": "UNIQLO"}, 2, []string{"A001", "A002"})
+	testCase(map[string]string{"item_id": "A003", "bmi": "22"}, 1, []string{"A001"})
+}
+
+func TestMemoryItemRepositoryClassification(t *testing.T) {
+	repo := databasetest.NewItemRepository()
+	result, err := repo.Classification(context.Background(), map[string]string{"index": "brands"})
+	if err != nil {
+		t.Fatalf("classification error:%v", err)
+	}
+	if result.Total != 2 || result.Hits[0].Title != "GU" {
+		t.Errorf("classification error:%+v", result.Hits)
+	}
+	if _, err := repo.Classification(context.Background(), map[string]string{"index": "shops"}); err == nil {
+		t.Errorf("unsupported index accepted")
+	}
+}
+
+func TestMemoryItemRepositoryClassificationSource(t *testing.T) {
+	repo := databasetest.NewItemRepository()
+	repo.Config.ClassificationSources = map[string]database.ClassificationSource{
+		"colors": {Filters: []string{"title"}, Sort: "title", Descending: true},
+	}
+	repo.Classifications["colors"] = []*domain.Classification{
+		{ID: "1", Title: "BLUE"},
+		{ID: "2", Title: "RED"},
+		{ID: "3", Title: "WHITE"},
+	}
+	result, err := repo.Classification(context.Background(), map[string]string{"index": "colors", "title": "BLUE,RED"})
+	if err != nil {
+		t.Fatalf("classification error:%v", err)
+	}
+	if result.Total != 2 || result.Hits[0].Title != "RED" || result.Hits[1].Title != "BLUE" {
+		t.Errorf("classification error:%+v", result.Hits)
+	}
+	if _, err := repo.Classification(context.Background(), map[string]string{"index": "colors", "gender": "MEN"}); err == nil {
+		t.Errorf("unknown filter accepted")
+	}
+	if _, err := repo.Classification(context.Background(), map[string]string{"index": "brands"}); err == nil {
+		t.Errorf("unregistered index accepted")
+	}
+
+	// domain.Classificationにない項目は絞り込みにも並び順にも使えない
+	repo.Config.ClassificationSources = map[string]database.ClassificationSource{
+		"colors": {Filters: []string{"id", "code"}, Sort: "sort_no"},
+		"sizes":  {Filters: []string{"title"}, Sort: "code"},
+	}
+	repo.Classifications["sizes"] = []*domain.Classification{{ID: "1", Title: "S"}}
+	result, err = repo.Classification(context.Background(), map[string]string{"index": "colors", "id": "3"})
+	if err != nil || result.Total != 1 || result.Hits[0].Title != "WHITE" {
+		t.Errorf("classification error:%+v %v", result, err)
+	}
+	_, err = repo.Classification(context.Background(), map[string]string{"index": "colors", "code": "red"})
+	var validationError *domain.ValidationError
+	if !errors.As(err, &validationError) || validationError.Fields[0].Field != "code" {
+		t.Errorf("unsupported filter error:%v", err)
+	}
+	if _, err := repo.Classification(context.Background(), map[string]string{"index": "sizes"}); err == nil {
+		t.Errorf("unsupported sort accepted")
+	}
+}
+
+func TestMemoryItemRepositoryClassificationTree(t *testing.T) {
+	repo := databasetest.NewItemRepository()
+	repo.Classifications["categories"] = append(repo.Classifications["categories"],
+		&domain.Classification{ID: "3", Title: "トップス", Gender: "MEN", SortNo: 0},
+		&domain.Classification{ID: "4", Title: "トップス", Gender: "WOMEN", SortNo: 0},
+	)
+	repo.Classifications["categories"][0].ParentID = "3"
+	result, err := repo.Classification(context.Background(), map[string]string{"index": "categories", "mode": "tree", "gender": "MEN"})
+	if err != nil {
+		t.Fatalf("classification error:%v", err)
+	}
+	if result.Total != 3 || len(result.Tree) != 2 || result.Tree[0].ID != "3" || result.Tree[1].Title != "パンツ" {
+		t.Fatalf("tree error:%+v", result.Tree)
+	}
+	// MENのシャツはA001, A002、パンツはA003
+	if tops := result.Tree[0]; tops.Count != 2 || len(tops.Children) != 1 || tops.Children[0].Title != "シャツ" || tops.Children[0].Count != 2 {
+		t.Errorf("tree children error:%+v", tops)
+	}
+	if result.Tree[1].Count != 1 {
+		t.Errorf("tree count error:%+v", result.Tree[1])
+	}
+
+	// 階層が崩れるため性別以外では絞り込めない
+	for _, filter := range []string{"title", "parent_id"} {
+		_, err := repo.Classification(context.Background(), map[string]string{"index": "categories", "mode": "tree", filter: "3"})
+		if validationErr, ok := err.(*domain.ValidationError); !ok || validationErr.Fields[0].Field != filter {
+			t.Errorf("tree filter %s error:%v", filter, err)
+		}
+	}
+
+	// 分類の設定の並び順で並べる
+	repo.Config.ClassificationSources = map[string]database.ClassificationSource{
+		"categories": {Filters: []string{"gender"}, Sort: "sort_no", Descending: true},
+	}
+	result, err = repo.Classification(context.Background(), map[string]string{"index": "categories", "mode": "tree", "gender": "MEN"})
+	if err != nil {
+		t.Fatalf("classification error:%v", err)
+	}
+	if len(result.Tree) != 2 || result.Tree[0].Title != "パンツ" {
+		t.Errorf("tree sort error:%+v", result.Tree)
+	}
+}
+
+func TestMemoryItemRepositoryBrand(t *testing.T) {
+	repo := databasetest.NewItemRepository()
+	detail, err := repo.Brand(context.Background(), map[string]string{"id": "2"})
+	if err != nil {
+		t.Fatalf("brand error:%v", err)
+	}
+	// UNIQLOはA001(値引き), A002
+	if detail.Brand.Title != "UNIQLO" || detail.ItemCount != 2 || *detail.MinPrice != 2990 || *detail.MaxPrice != 3990 || detail.DiscountShare != 0.5 {
+		t.Errorf("brand error:%+v", detail)
+	}
+	if len(detail.Categories) != 1 || detail.Categories[0].Count != 2 || len(detail.Genders) != 1 || detail.Genders[0].Key != "MEN" {
+		t.Errorf("brand buckets error:%+v %+v", detail.Categories, detail.Genders)
+	}
+	_, err = repo.Brand(context.Background(), map[string]string{"id": "9"})
+	if notFound, ok := err.(*domain.NotFoundError); !ok || notFound.Resource != "brand" {
+		t.Errorf("unknown brand error:%v", err)
+	}
+}
+
+func TestMemoryItemRepositorySuggest(t *testing.T) {
+	repo := databasetest.NewItemRepository()
+	suggestions, err := repo.Suggest(context.Background(), map[string]string{"keywords": "ｕｎｉ"})
+	if err != nil {
+		t.Fatalf("suggest error:%v", err)
+	}
+	if len(suggestions.Brands) != 1 || suggestions.Brands[0].Text != "UNIQLO" || suggestions.Brands[0].Count != 2 {
+		t.Errorf("brand suggestions error:%+v", suggestions.Brands)
+	}
+	if len(suggestions.Keywords) != 0 {
+		t.Errorf("keyword suggestions error:%+v", suggestions.Keywords)
+	}
+
+	// キーワードは入力で始まる語を含む商品名を返却する
+	suggestions, err = repo.Suggest(context.Background(), map[string]string{"keywords": "ﾃﾞﾆ"})
+	if err != nil {
+		t.Fatalf("suggest error:%v", err)
+	}
+	if len(suggestions.Keywords) != 1 || suggestions.Keywords[0].Text != "デニムシャツ" || suggestions.Keywords[0].Count != 1 {
+		t.Errorf("keyword suggestions error:%+v", suggestions.Keywords)
+	}
+}
+
+func TestMemoryItemRepositoryRecordAccess(t *testing.T) {
+	repo := databasetest.NewItemRepository()
+	accessedAt := time.Date(2020, 3, 2, 0, 0, 0, 0, time.UTC)
+	if err := repo.RecordAccess(context.Background(), []*domain.AccessEvent{
+		{ItemID: "A001", Count: 3, AccessedAt: accessedAt},
+		{ItemID: "UNKNOWN", Count: 1, AccessedAt: accessedAt},
+	}); err != nil {
+		t.Fatalf("record access error:%v", err)
+	}
+	if item := repo.Items[0]; item.AccessCounter != 3 || !item.LastAccessedAt.Equal(accessedAt) {
+		t.Errorf("access counter error:%+v", item)
+	}
+}
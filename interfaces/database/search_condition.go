@@ -0,0 +1,296 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akaishi-sandbox/sam-go/domain"
+	elastic "github.com/olivere/elastic/v7"
+)
+
+// SearchConfig 検索の設定、既定値を含めて全ての項目を設定したものを使う
+type SearchConfig struct {
+	// Indices 検索するインデックスの名前
+	Indices Indices
+	// DefaultLimit 商品の一覧でlimitを指定しない場合の件数
+	DefaultLimit int
+	// ClassificationLimit 分類の一覧でlimitを指定しない場合の件数
+	ClassificationLimit int
+	// PopularityHalfLife 人気順で最終アクセス日時からの経過によりアクセス回数の評価が半分になる期間
+	PopularityHalfLife time.Duration
+	// TrendingHalfLife 急上昇順の半減期、人気順より短くして最近のアクセスを重視する
+	TrendingHalfLife time.Duration
+	// RecommendFallbacks おすすめ商品が見つからない場合に順に試す方法、空の場合は使わない
+	RecommendFallbacks []string
+	// ClassificationSources classification-infoのindexで指定できる分類
+	ClassificationSources map[string]ClassificationSource
+}
+
+// Indices 検索するインデックスの名前
+type Indices struct {
+	Items      string
+	Categories string
+	Brands     string
+	// AccessEvents セッション毎のアクセスを記録するインデックス
+	AccessEvents string
+}
+
+// ClassificationSource 分類の一覧を返却するインデックスの設定
+type ClassificationSource struct {
+	// Index 検索するインデックス、省略した場合は登録した名前と同じ
+	Index string `json:"index,omitempty"`
+	// Filters 絞り込みに使える項目、これ以外の項目を指定した場合はエラーとする
+	Filters []string `json:"filters"`
+	// Sort 並び順の項目、省略した場合はsort_no
+	Sort string `json:"sort,omitempty"`
+	// Descending 降順に並べる
+	Descending bool `json:"descending,omitempty"`
+}
+
+// Validate 検索の方法や分類の設定の誤りを全て返却する、起動時に確認するために使う
+func (config SearchConfig) Validate() []string {
+	var problems []string
+	for _, tier := range config.RecommendFallbacks {
+		if !domain.RecommendFallbacks.Contains(tier) {
+			problems = append(problems, fmt.Sprintf("search.recommend_fallbacks %s is not supported", tier))
+		}
+	}
+	names := make([]string, 0, len(config.ClassificationSources))
+	for name := range config.ClassificationSources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if len(name) == 0 {
+			problems = append(problems, "search.classification_sources name is required")
+		}
+		for _, filter := range config.ClassificationSources[name].Filters {
+			if containsString(classificationParameters, filter) {
+				problems = append(problems, fmt.Sprintf("search.classification_sources.%s.filters %s is reserved", name, filter))
+			}
+		}
+	}
+	return problems
+}
+
+// fieldFilter 絞り込み件数を返却できる項目の条件
+type fieldFilter struct {
+	name   string
+	field  string
+	values []string
+	min    *int
+	max    *int
+}
+
+func (f *fieldFilter) query() elastic.Query {
+	switch {
+	case f.min != nil:
+		return elastic.NewRangeQuery(f.field).Gte(*f.min)
+	case f.max != nil:
+		return elastic.NewRangeQuery(f.field).Lte(*f.max)
+	default:
+		return newTermsString(f.field, f.values)
+	}
+}
+
+func (f *fieldFilter) match(item *domain.Item) bool {
+	switch {
+	case f.min != nil:
+		return item.LowestPrice >= *f.min
+	case f.max != nil:
+		return item.LowestPrice <= *f.max
+	default:
+		return containsString(f.values, itemField(item, f.field))
+	}
+}
+
+// searchCondition 商品検索の条件、Elasticsearchとメモリ上の実装で同じ条件を評価するためにパラメータを解析したもの
+type searchCondition struct {
+	itemIDs        []string
+	filters        []fieldFilter
+	minBmi         *float64
+	maxBmi         *float64
+	keywords       *domain.KeywordAnd
+	excludeExpired bool
+	facets         []string
+	highlight      bool
+	halfLife       time.Duration
+	origin         time.Time
+	order          string
+	sort           elastic.SortInfo
+	from           int
+	size           int
+	cursor         domain.Cursor
+}
+
+func splitParameter(q map[string]string, name string) []string {
+	if value, ok := q[name]; ok && len(value) > 0 {
+		return strings.Split(value, ",")
+	}
+	return nil
+}
+
+// parsePaging offset, limitを解析する、解析できない値は無視する
+func parsePaging(q map[string]string, size int) (int, int) {
+	from := 0
+	if offset, ok := q["offset"]; ok {
+		if v, err := strconv.Atoi(offset); err == nil {
+			from = v
+		}
+	}
+	if limit, ok := q["limit"]; ok {
+		if v, err := strconv.Atoi(limit); err == nil {
+			size = v
+		}
+	}
+	return from, size
+}
+
+// searchOrder 並び順、指定されていない場合は新着順とする
+func searchOrder(q map[string]string) string {
+	if order, ok := q["order"]; ok && len(order) > 0 {
+		return order
+	}
+	return domain.OrderNew
+}
+
+func parseSearchCondition(q map[string]string, config SearchConfig) (*searchCondition, error) {
+	condition := &searchCondition{
+		itemIDs: splitParameter(q, "item_id"),
+	}
+	for _, name := range []string{domain.FacetGender, domain.FacetBrand, domain.FacetCategory, domain.FacetDiscountFlag} {
+		if values := splitParameter(q, name); values != nil {
+			condition.filters = append(condition.filters, fieldFilter{name: name, field: name, values: values})
+		}
+	}
+	if minPrice, ok := q["min_price"]; ok {
+		if price, err := strconv.Atoi(minPrice); err == nil {
+			condition.filters = append(condition.filters, fieldFilter{name: domain.FacetPrice, field: "lowest_price", min: &price})
+		}
+	}
+	if maxPrice, ok := q["max_price"]; ok {
+		if price, err := strconv.Atoi(maxPrice); err == nil {
+			condition.filters = append(condition.filters, fieldFilter{name: domain.FacetPrice, field: "lowest_price", max: &price})
+		}
+	}
+	if minBmi, ok := q["min_bmi"]; ok {
+		if bmi, err := strconv.ParseFloat(minBmi, 64); err == nil {
+			condition.minBmi = &bmi
+		}
+	}
+	if maxBmi, ok := q["max_bmi"]; ok {
+		if bmi, err := strconv.ParseFloat(maxBmi, 64); err == nil {
+			condition.maxBmi = &bmi
+		}
+	}
+	if keywords, ok := q["keywords"]; ok && len(keywords) > 0 {
+		and, err := domain.ParseKeywords(keywords)
+		if err != nil {
+			return nil, domain.NewValidationError("keywords", err.Error())
+		}
+		condition.keywords = and
+	}
+	if excludeExpired, ok := q["exclude_expired"]; ok && excludeExpired == "1" {
+		condition.excludeExpired = true
+	}
+	condition.facets = parseFacets(q)
+	if highlight, ok := q["highlight"]; ok && highlight == "1" {
+		condition.highlight = true
+	}
+
+	condition.from, condition.size = parsePaging(q, config.DefaultLimit)
+
+	condition.order = searchOrder(q)
+	condition.sort = elastic.SortInfo{Field: "updated_at", Ascending: false}
+	switch condition.order {
+	case domain.OrderNew:
+	case domain.OrderMinPrice:
+		condition.sort.Field = "lowest_price"
+		condition.sort.Ascending = true
+	case domain.OrderMaxPrice:
+		condition.sort.Field = "lowest_price"
+		condition.sort.Ascending = false
+	case domain.OrderPopular:
+		condition.sort.Field = "_score"
+		condition.halfLife = config.PopularityHalfLife
+	case domain.OrderTrending:
+		condition.sort.Field = "_score"
+		condition.halfLife = config.TrendingHalfLife
+	}
+	if cursor, ok := q["cursor"]; ok && len(cursor) > 0 {
+		searchAfter, err := domain.ParseCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		// 並び順が異なるとsearch_afterの値の意味が変わるため使えない
+		if searchAfter.Order != condition.order {
+			return nil, domain.NewValidationError("cursor", "does not match order")
+		}
+		// search_afterはfromと併用できないため、カーソル指定時はoffsetを無視する
+		condition.cursor = searchAfter
+		condition.from = 0
+	}
+	// 続きのページは最初のページと同じ日時を基準にスコアを求める
+	if condition.halfLife > 0 {
+		if condition.cursor.Origin > 0 {
+			condition.origin = time.Unix(0, condition.cursor.Origin*int64(time.Millisecond))
+		} else {
+			condition.origin = time.Now().Truncate(time.Millisecond)
+		}
+	}
+	return condition, nil
+}
+
+// parseFitBmi 体型に合う商品を探すためのBMIを解析する、bmiがない場合は身長(cm)と体重(kg)から計算する
+func parseFitBmi(q map[string]string) (float64, bool, error) {
+	if value, ok := q["bmi"]; ok && len(value) > 0 {
+		bmi, err := strconv.ParseFloat(value, 64)
+		if err != nil || bmi <= 0 {
+			return 0, false, domain.NewValidationError("bmi", "is invalid")
+		}
+		return bmi, true, nil
+	}
+	height, hasHeight := q["height"]
+	weight, hasWeight := q["weight"]
+	if !hasHeight && !hasWeight {
+		return 0, false, nil
+	}
+	h, err := strconv.ParseFloat(height, 64)
+	if err != nil || h <= 0 {
+		return 0, false, domain.NewValidationError("height", "is invalid")
+	}
+	w, err := strconv.ParseFloat(weight, 64)
+	if err != nil || w <= 0 {
+		return 0, false, domain.NewValidationError("weight", "is invalid")
+	}
+	return w / (h / 100) / (h / 100), true, nil
+}
+
+// formatDuration Elasticsearchの時間の単位の文字列に変換する
+func formatDuration(d time.Duration) string {
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("%dh", d/time.Hour)
+	}
+	return fmt.Sprintf("%dms", d/time.Millisecond)
+}
+
+// dateLayout Elasticsearchに渡す日時の形式
+const dateLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// createPopularityQuery アクセス回数を最終アクセス日時からの経過で指数的に減衰させた値をスコアとする
+// originを指定しない場合は検索した時点を基準とする
+func createPopularityQuery(query elastic.Query, halfLife time.Duration, origin time.Time) elastic.Query {
+	var decayOrigin interface{} = "now"
+	if !origin.IsZero() {
+		decayOrigin = origin.UTC().Format(dateLayout)
+	}
+	return elastic.NewFunctionScoreQuery().
+		Query(query).
+		AddScoreFunc(elastic.NewFieldValueFactorFunction().Field("access_counter").Modifier("log1p").Missing(0)).
+		AddScoreFunc(elastic.NewExponentialDecayFunction().FieldName("last_accessed_at").Origin(decayOrigin).Scale(formatDuration(halfLife)).Decay(0.5)).
+		ScoreMode("multiply").
+		BoostMode("replace")
+}
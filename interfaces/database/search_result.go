@@ -0,0 +1,288 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/akaishi-sandbox/sam-go/domain"
+	elastic "github.com/olivere/elastic/v7"
+)
+
+func newItem(hit *elastic.SearchHit) (*domain.Item, error) {
+	var item domain.Item
+	if err := json.Unmarshal(hit.Source, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// newFitSKU inner_hitsから最も体型に合うSKUを取り出す
+func newFitSKU(hit *elastic.SearchHit) (*domain.SKU, error) {
+	innerHits, ok := hit.InnerHits[sizeFitInnerHit]
+	if !ok || innerHits.Hits == nil || len(innerHits.Hits.Hits) == 0 {
+		return nil, nil
+	}
+	var sku domain.SKU
+	if err := json.Unmarshal(innerHits.Hits.Hits[0].Source, &sku); err != nil {
+		return nil, err
+	}
+	return &sku, nil
+}
+
+// newCursor 最後の商品の並び順の値から続きを取得するカーソルを作成する、size件に満たない最後のページでは返却しない
+func newCursor(hits []*elastic.SearchHit, order string, origin time.Time, size int) string {
+	if len(hits) == 0 || len(hits) < size {
+		return ""
+	}
+	return domain.Cursor{Order: order, After: hits[len(hits)-1].Sort, Origin: originMillis(origin)}.String()
+}
+
+// originMillis スコアの減衰の基準日時をカーソルに保持するミリ秒に変換する
+func originMillis(origin time.Time) int64 {
+	if origin.IsZero() {
+		return 0
+	}
+	return origin.UnixNano() / int64(time.Millisecond)
+}
+
+func newFacetBuckets(aggregations elastic.Aggregations, name string) []domain.FacetBucket {
+	filter, ok := aggregations.Filter(name)
+	if !ok {
+		return nil
+	}
+	buckets := []domain.FacetBucket{}
+	if name == domain.FacetPrice {
+		if items, ok := filter.Range(name); ok {
+			for _, bucket := range items.Buckets {
+				buckets = append(buckets, domain.FacetBucket{
+					Key:   bucket.Key,
+					From:  bucket.From,
+					To:    bucket.To,
+					Count: bucket.DocCount,
+				})
+			}
+		}
+		return buckets
+	}
+	if items, ok := filter.Terms(name); ok {
+		for _, bucket := range items.Buckets {
+			key := fmt.Sprint(bucket.Key)
+			if bucket.KeyAsString != nil {
+				key = *bucket.KeyAsString
+			}
+			buckets = append(buckets, domain.FacetBucket{
+				Key:   key,
+				Count: bucket.DocCount,
+			})
+		}
+	}
+	return buckets
+}
+
+func newFacets(aggregations elastic.Aggregations) *domain.Facets {
+	if len(aggregations) == 0 {
+		return nil
+	}
+	return &domain.Facets{
+		Brand:        newFacetBuckets(aggregations, domain.FacetBrand),
+		Category:     newFacetBuckets(aggregations, domain.FacetCategory),
+		Gender:       newFacetBuckets(aggregations, domain.FacetGender),
+		DiscountFlag: newFacetBuckets(aggregations, domain.FacetDiscountFlag),
+		Price:        newFacetBuckets(aggregations, domain.FacetPrice),
+	}
+}
+
+func newSearchResult(searchResult *elastic.SearchResult) (*domain.SearchResult, error) {
+	result := &domain.SearchResult{
+		Total:  searchResult.TotalHits(),
+		Items:  []*domain.SearchItem{},
+		Facets: newFacets(searchResult.Aggregations),
+	}
+	if searchResult.Hits == nil {
+		return result, nil
+	}
+	for _, hit := range searchResult.Hits.Hits {
+		item, err := newItem(hit)
+		if err != nil {
+			return nil, err
+		}
+		fitSKU, err := newFitSKU(hit)
+		if err != nil {
+			return nil, err
+		}
+		result.Items = append(result.Items, &domain.SearchItem{
+			Item:      item,
+			Highlight: hit.Highlight,
+			FitSKU:    fitSKU,
+		})
+	}
+	return result, nil
+}
+
+func newClassificationResult(searchResult *elastic.SearchResult) (*domain.ClassificationResult, error) {
+	result := &domain.ClassificationResult{
+		Total: searchResult.TotalHits(),
+		Hits:  []*domain.Classification{},
+	}
+	if searchResult.Hits == nil {
+		return result, nil
+	}
+	for _, hit := range searchResult.Hits.Hits {
+		var classification domain.Classification
+		if err := json.Unmarshal(hit.Source, &classification); err != nil {
+			return nil, err
+		}
+		classification.ID = hit.Id
+		result.Hits = append(result.Hits, &classification)
+	}
+	return result, nil
+}
+
+// newClassificationTree 親の分類の子として並べる、親が含まれない分類は最上位とする
+// 分類は表示順に並んでいるものとし、子の順序もその順とする
+func newClassificationTree(classifications []*domain.Classification, counts map[string]int64) []*domain.ClassificationNode {
+	nodes := make(map[string]*domain.ClassificationNode, len(classifications))
+	for _, classification := range classifications {
+		copied := *classification
+		nodes[classification.ID] = &domain.ClassificationNode{
+			Classification: &copied,
+			Children:       []*domain.ClassificationNode{},
+		}
+	}
+	roots := []*domain.ClassificationNode{}
+	for _, classification := range classifications {
+		node := nodes[classification.ID]
+		if parent, ok := nodes[classification.ParentID]; ok && classification.ParentID != classification.ID {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	var count func(node *domain.ClassificationNode) int64
+	count = func(node *domain.ClassificationNode) int64 {
+		node.Count = counts[node.Title]
+		for _, child := range node.Children {
+			node.Count += count(child)
+		}
+		return node.Count
+	}
+	for _, root := range roots {
+		count(root)
+	}
+	return roots
+}
+
+// newClassificationTreeResult categories, itemsの順の検索結果から階層構造の分類を作成する
+func newClassificationTreeResult(searchResult *elastic.MultiSearchResult) (*domain.ClassificationResult, error) {
+	if len(searchResult.Responses) != 2 {
+		return nil, fmt.Errorf("invalid classification tree response")
+	}
+	result, err := newClassificationResult(searchResult.Responses[0])
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64)
+	if terms, ok := searchResult.Responses[1].Aggregations.Terms("categories"); ok {
+		for _, bucket := range terms.Buckets {
+			counts[fmt.Sprint(bucket.Key)] = bucket.DocCount
+		}
+	}
+	result.Tree = newClassificationTree(result.Hits, counts)
+	return result, nil
+}
+
+func newTermsFacetBuckets(aggregations elastic.Aggregations, name string) []domain.FacetBucket {
+	buckets := []domain.FacetBucket{}
+	if items, ok := aggregations.Terms(name); ok {
+		for _, bucket := range items.Buckets {
+			buckets = append(buckets, domain.FacetBucket{
+				Key:   fmt.Sprint(bucket.Key),
+				Count: bucket.DocCount,
+			})
+		}
+	}
+	return buckets
+}
+
+func newPrice(metric *elastic.AggregationValueMetric, ok bool) *int {
+	if !ok || metric.Value == nil {
+		return nil
+	}
+	price := int(*metric.Value)
+	return &price
+}
+
+// newBrandDetail createBrandItemsQueryの集計からブランドの詳細を作成する
+func newBrandDetail(brand *domain.Classification, searchResult *elastic.SearchResult) *domain.BrandDetail {
+	detail := &domain.BrandDetail{
+		Brand:      brand,
+		ItemCount:  searchResult.TotalHits(),
+		MinPrice:   newPrice(searchResult.Aggregations.Min("min_price")),
+		MaxPrice:   newPrice(searchResult.Aggregations.Max("max_price")),
+		Categories: newTermsFacetBuckets(searchResult.Aggregations, "categories"),
+		Genders:    newTermsFacetBuckets(searchResult.Aggregations, "genders"),
+	}
+	if discount, ok := searchResult.Aggregations.Filter("discount"); ok && detail.ItemCount > 0 {
+		detail.DiscountShare = float64(discount.DocCount) / float64(detail.ItemCount)
+	}
+	return detail
+}
+
+// suggestBuckets newSuggestAggregationで集計した値
+func suggestBuckets(aggregations elastic.Aggregations, name string) []*elastic.AggregationBucketKeyItem {
+	filter, ok := aggregations.Filter(name)
+	if !ok {
+		return nil
+	}
+	values, ok := filter.Terms("values")
+	if !ok {
+		return nil
+	}
+	return values.Buckets
+}
+
+func newClassificationSuggestions(searchResult *elastic.SearchResult, counts []*elastic.AggregationBucketKeyItem) ([]domain.Suggestion, error) {
+	itemCounts := make(map[string]int64)
+	for _, bucket := range counts {
+		itemCounts[fmt.Sprint(bucket.Key)] = bucket.DocCount
+	}
+	classifications, err := newClassificationResult(searchResult)
+	if err != nil {
+		return nil, err
+	}
+	suggestions := []domain.Suggestion{}
+	for _, classification := range classifications.Hits {
+		suggestions = append(suggestions, domain.Suggestion{
+			Text:  classification.Title,
+			Count: itemCounts[classification.Title],
+		})
+	}
+	return suggestions, nil
+}
+
+// newSuggestions brands, categories, itemsの順の検索結果から候補を作成する
+func newSuggestions(searchResult *elastic.MultiSearchResult) (*domain.Suggestions, error) {
+	if len(searchResult.Responses) != 3 {
+		return nil, fmt.Errorf("invalid suggest response")
+	}
+	brands, categories, items := searchResult.Responses[0], searchResult.Responses[1], searchResult.Responses[2]
+
+	suggestions := &domain.Suggestions{
+		Keywords: []domain.Suggestion{},
+	}
+	for _, bucket := range suggestBuckets(items.Aggregations, "keywords") {
+		suggestions.Keywords = append(suggestions.Keywords, domain.Suggestion{
+			Text:  fmt.Sprint(bucket.Key),
+			Count: bucket.DocCount,
+		})
+	}
+	var err error
+	if suggestions.Brands, err = newClassificationSuggestions(brands, suggestBuckets(items.Aggregations, "brands")); err != nil {
+		return nil, err
+	}
+	if suggestions.Categories, err = newClassificationSuggestions(categories, suggestBuckets(items.Aggregations, "categories")); err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,223 @@
+package database
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/akaishi-sandbox/sam-go/domain"
+	elastic "github.com/olivere/elastic/v7"
+)
+
+func TestNewSearchResult(t *testing.T) {
+	var searchResult elastic.SearchResult
+	if err := json.Unmarshal([]byte(`{
+		"hits": {
+			"total": {"value": 2, "relation": "eq"},
+			"hits": [
+				{
+					"_index": "items", "_id": "1", "_score": null, "sort": [1583020800000, "123456AA"],
+					"_source": {"item_id": "123456AA", "title": "オックスフォードシャツ", "brand": "UNIQLO", "gender": "MEN", "category": "シャツ", "lowest_price": 2990, "highest_price": 3990, "discount_flag": 1, "release_flag": 1, "updated_at": "2020-03-01T00:00:00Z", "images": ["https://example.com/1.jpg"], "SKUs": [{"size": "M", "bmi": 22.5, "stock": 3}]},
+					"highlight": {"search_text": ["<em>シャツ</em>"]}
+				},
+				{
+					"_index": "items", "_id": "2", "_score": null, "sort": [1583020800000, "123456AB"],
+					"_source": {"item_id": "123456AB", "brand": "UNIQLO", "gender": "MEN", "category": "シャツ"},
+					"inner_hits": {"fit_sku": {"hits": {"total": {"value": 1, "relation": "eq"}, "hits": [{"_index": "items", "_id": "2", "_nested": {"field": "SKUs", "offset": 1}, "_source": {"size": "L", "bmi": 24, "stock": 2}}]}}}
+				}
+			]
+		},
+		"aggregations": {
+			"brand": {"doc_count": 2, "brand": {"buckets": [{"key": "UNIQLO", "doc_count": 2}]}},
+			"discount_flag": {"doc_count": 2, "discount_flag": {"buckets": [{"key": 1, "doc_count": 1}, {"key": 0, "doc_count": 1}]}},
+			"price": {"doc_count": 2, "price": {"buckets": [{"key": "1000.0-3000.0", "from": 1000, "to": 3000, "doc_count": 1}]}}
+		}
+	}`), &searchResult); err != nil {
+		t.Fatalf("fixture error:%v", err)
+	}
+
+	result, err := newSearchResult(&searchResult)
+	if err != nil {
+		t.Fatalf("newSearchResult error:%v", err)
+	}
+	if result.Total != 2 || len(result.Items) != 2 {
+		t.Fatalf("result error:%d %d", result.Total, len(result.Items))
+	}
+	item := result.Items[0]
+	if item.ItemID != "123456AA" || item.Title != "オックスフォードシャツ" || item.LowestPrice != 2990 || item.DiscountFlag != 1 || item.UpdatedAt.IsZero() {
+		t.Errorf("item error:%+v", item.Item)
+	}
+	if len(item.SKUs) != 1 || item.SKUs[0].Size != "M" || item.SKUs[0].Bmi != 22.5 || item.SKUs[0].Stock != 3 {
+		t.Errorf("item SKUs error:%+v", item.SKUs)
+	}
+	if len(item.Highlight["search_text"]) != 1 {
+		t.Errorf("item highlight error:%v", item.Highlight)
+	}
+	if item.FitSKU != nil {
+		t.Errorf("fit sku without inner hits:%+v", item.FitSKU)
+	}
+	if sku := result.Items[1].FitSKU; sku == nil || sku.Size != "L" || sku.Bmi != 24 || sku.Stock != 2 {
+		t.Errorf("fit sku error:%+v", sku)
+	}
+	if result.Facets == nil || len(result.Facets.Brand) != 1 || result.Facets.Brand[0].Count != 2 || len(result.Facets.Price) != 1 {
+		t.Errorf("facets error:%+v", result.Facets)
+	}
+	if result.Facets.DiscountFlag[0].Key != "1" {
+		t.Errorf("discount flag key error:%s", result.Facets.DiscountFlag[0].Key)
+	}
+
+	j, err := json.Marshal(result.Items[1])
+	if err != nil {
+		t.Errorf("item not marshal:%v", err)
+	}
+	var source map[string]interface{}
+	if err := json.Unmarshal(j, &source); err != nil {
+		t.Errorf("item not unmarshal:%v", err)
+	}
+	for _, key := range []string{"_index", "_id", "_score", "_source"} {
+		if _, ok := source[key]; ok {
+			t.Errorf("item has search hit field:%s", key)
+		}
+	}
+	if source["item_id"] != "123456AB" {
+		t.Errorf("item json error:%s", j)
+	}
+}
+
+func TestNewClassificationTreeResult(t *testing.T) {
+	var categories, items elastic.SearchResult
+	if err := json.Unmarshal([]byte(`{
+		"hits": {
+			"total": {"value": 4, "relation": "eq"},
+			"hits": [
+				{"_index": "categories", "_id": "1", "_source": {"title": "トップス", "gender": "MEN", "sort_no": 1}},
+				{"_index": "categories", "_id": "2", "_source": {"title": "シャツ", "gender": "MEN", "sort_no": 2, "parent_id": "1"}},
+				{"_index": "categories", "_id": "3", "_source": {"title": "Tシャツ", "gender": "MEN", "sort_no": 3, "parent_id": "1"}},
+				{"_index": "categories", "_id": "4", "_source": {"title": "パンツ", "gender": "MEN", "sort_no": 4, "parent_id": "9"}}
+			]
+		}
+	}`), &categories); err != nil {
+		t.Fatalf("fixture error:%v", err)
+	}
+	if err := json.Unmarshal([]byte(`{
+		"hits": {"total": {"value": 6, "relation": "eq"}, "hits": []},
+		"aggregations": {"categories": {"buckets": [{"key": "シャツ", "doc_count": 3}, {"key": "Tシャツ", "doc_count": 2}, {"key": "パンツ", "doc_count": 1}]}}
+	}`), &items); err != nil {
+		t.Fatalf("fixture error:%v", err)
+	}
+
+	result, err := newClassificationTreeResult(&elastic.MultiSearchResult{Responses: []*elastic.SearchResult{&categories, &items}})
+	if err != nil {
+		t.Fatalf("newClassificationTreeResult error:%v", err)
+	}
+	if result.Total != 4 || len(result.Hits) != 4 {
+		t.Errorf("result error:%d %d", result.Total, len(result.Hits))
+	}
+	// 親が含まれないパンツは最上位とする
+	if len(result.Tree) != 2 || result.Tree[0].Title != "トップス" || result.Tree[1].Title != "パンツ" {
+		t.Fatalf("tree error:%+v", result.Tree)
+	}
+	tops := result.Tree[0]
+	if tops.Count != 5 || len(tops.Children) != 2 || tops.Children[0].Title != "シャツ" || tops.Children[0].Count != 3 || tops.Children[1].ID != "3" {
+		t.Errorf("tree children error:%+v", tops)
+	}
+	if result.Tree[1].Count != 1 || len(result.Tree[1].Children) != 0 {
+		t.Errorf("tree root error:%+v", result.Tree[1])
+	}
+}
+
+func TestNewBrandDetail(t *testing.T) {
+	var searchResult elastic.SearchResult
+	if err := json.Unmarshal([]byte(`{
+		"hits": {"total": {"value": 4, "relation": "eq"}, "hits": []},
+		"aggregations": {
+			"min_price": {"value": 990.0},
+			"max_price": {"value": 5990.0},
+			"categories": {"buckets": [{"key": "シャツ", "doc_count": 3}, {"key": "パンツ", "doc_count": 1}]},
+			"genders": {"buckets": [{"key": "MEN", "doc_count": 4}]},
+			"discount": {"doc_count": 1}
+		}
+	}`), &searchResult); err != nil {
+		t.Fatalf("fixture error:%v", err)
+	}
+	detail := newBrandDetail(&domain.Classification{ID: "12", Title: "UNIQLO"}, &searchResult)
+	if detail.Brand.ID != "12" || detail.ItemCount != 4 || *detail.MinPrice != 990 || *detail.MaxPrice != 5990 || detail.DiscountShare != 0.25 {
+		t.Errorf("brand detail error:%+v", detail)
+	}
+	if len(detail.Categories) != 2 || detail.Categories[0].Key != "シャツ" || len(detail.Genders) != 1 || detail.Genders[0].Count != 4 {
+		t.Errorf("brand detail buckets error:%+v %+v", detail.Categories, detail.Genders)
+	}
+
+	if err := json.Unmarshal([]byte(`{
+		"hits": {"total": {"value": 0, "relation": "eq"}, "hits": []},
+		"aggregations": {"min_price": {"value": null}, "max_price": {"value": null}, "categories": {"buckets": []}, "genders": {"buckets": []}, "discount": {"doc_count": 0}}
+	}`), &searchResult); err != nil {
+		t.Fatalf("fixture error:%v", err)
+	}
+	detail = newBrandDetail(&domain.Classification{ID: "13", Title: "GU"}, &searchResult)
+	if detail.ItemCount != 0 || detail.MinPrice != nil || detail.MaxPrice != nil || detail.DiscountShare != 0 || detail.Categories == nil {
+		t.Errorf("empty brand detail error:%+v", detail)
+	}
+}
+
+func TestNewSuggestions(t *testing.T) {
+	var brands, categories, items elastic.SearchResult
+	if err := json.Unmarshal([]byte(`{
+		"hits": {"total": {"value": 1, "relation": "eq"}, "hits": [{"_index": "brands", "_id": "2", "_source": {"title": "UNIQLO", "sort_no": 2}}]}
+	}`), &brands); err != nil {
+		t.Fatalf("fixture error:%v", err)
+	}
+	if err := json.Unmarshal([]byte(`{"hits": {"total": {"value": 0, "relation": "eq"}, "hits": []}}`), &categories); err != nil {
+		t.Fatalf("fixture error:%v", err)
+	}
+	if err := json.Unmarshal([]byte(`{
+		"hits": {"total": {"value": 4, "relation": "eq"}, "hits": []},
+		"aggregations": {
+			"keywords": {"doc_count": 3, "values": {"buckets": [{"key": "UNIQLO U クルーネックT", "doc_count": 2}, {"key": "UNIQLO U シャツ", "doc_count": 1}]}},
+			"brands": {"doc_count": 2, "values": {"buckets": [{"key": "UNIQLO", "doc_count": 2}]}},
+			"categories": {"doc_count": 0, "values": {"buckets": []}}
+		}
+	}`), &items); err != nil {
+		t.Fatalf("fixture error:%v", err)
+	}
+
+	suggestions, err := newSuggestions(&elastic.MultiSearchResult{Responses: []*elastic.SearchResult{&brands, &categories, &items}})
+	if err != nil {
+		t.Fatalf("newSuggestions error:%v", err)
+	}
+	if len(suggestions.Keywords) != 2 || suggestions.Keywords[0].Text != "UNIQLO U クルーネックT" || suggestions.Keywords[0].Count != 2 {
+		t.Errorf("keyword suggestions error:%+v", suggestions.Keywords)
+	}
+	if len(suggestions.Brands) != 1 || suggestions.Brands[0].Text != "UNIQLO" || suggestions.Brands[0].Count != 2 {
+		t.Errorf("brand suggestions error:%+v", suggestions.Brands)
+	}
+	if suggestions.Categories == nil || len(suggestions.Categories) != 0 {
+		t.Errorf("category suggestions error:%+v", suggestions.Categories)
+	}
+}
+
+func TestNewCursor(t *testing.T) {
+	hits := []*elastic.SearchHit{
+		{Id: "1", Sort: []interface{}{2990.0, "123456AA"}},
+		{Id: "2", Sort: []interface{}{3990.0, "123456AB"}},
+	}
+	cursor, err := domain.ParseCursor(newCursor(hits, "min-max", time.Time{}, 2))
+	if err != nil {
+		t.Fatalf("cursor error:%v", err)
+	}
+	if cursor.Order != "min-max" || len(cursor.After) != 2 || cursor.After[1] != "123456AB" || cursor.Origin != 0 {
+		t.Errorf("cursor error:%+v", cursor)
+	}
+	// 件数に満たない最後のページでは続きがない
+	// 人気順は続きのページで同じスコアになるよう減衰の基準日時を引き継ぐ
+	origin := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	if cursor, err := domain.ParseCursor(newCursor(hits, "popular", origin, 2)); err != nil || cursor.Origin != 1583020800000 {
+		t.Errorf("popular cursor error:%+v %v", cursor, err)
+	}
+	if cursor := newCursor(hits, "min-max", time.Time{}, 3); cursor != "" {
+		t.Errorf("cursor on last page:%s", cursor)
+	}
+	if cursor := newCursor(nil, "new", time.Time{}, 0); cursor != "" {
+		t.Errorf("cursor without hits:%s", cursor)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	echolamda "github.com/awslabs/aws-lambda-go-api-proxy/echo"
+	"github.com/labstack/echo"
+)
+
+// Lambdaを呼び出すイベントの形式
+const (
+	// eventTypeAPIGateway API GatewayのREST API (v1)
+	eventTypeAPIGateway = "api_gateway"
+	// eventTypeAPIGatewayV2 API GatewayのHTTP APIとFunction URL (v2)
+	eventTypeAPIGatewayV2 = "api_gateway_v2"
+	// eventTypeALB Application Load Balancer
+	eventTypeALB = "alb"
+)
+
+// eventProbe イベントの形式を判定するための項目
+type eventProbe struct {
+	Version        string `json:"version"`
+	RequestContext struct {
+		ELB json.RawMessage `json:"elb"`
+	} `json:"requestContext"`
+}
+
+// detectEventType ALBはrequestContext.elb、v2はversionで判定し、それ以外はREST APIとする
+func detectEventType(payload json.RawMessage) (string, error) {
+	var probe eventProbe
+	if err := json.Unmarshal(payload, &probe); err != nil {
+		return "", err
+	}
+	switch {
+	case len(probe.RequestContext.ELB) > 0:
+		return eventTypeALB, nil
+	case probe.Version == "2.0":
+		return eventTypeAPIGatewayV2, nil
+	default:
+		return eventTypeAPIGateway, nil
+	}
+}
+
+// proxyEvent イベントをHTTPリクエストに変換してechoで処理し、イベントの形式に合うレスポンスを返却する
+func proxyEvent(ctx context.Context, e *echo.Echo, payload json.RawMessage) (interface{}, error) {
+	eventType, err := detectEventType(payload)
+	if err != nil {
+		return nil, err
+	}
+	switch eventType {
+	case eventTypeALB:
+		var event events.ALBTargetGroupRequest
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+		req, err := newALBHTTPRequest(ctx, event)
+		if err != nil {
+			return nil, err
+		}
+		w := newEventResponseWriter()
+		e.ServeHTTP(w, req)
+		// 複数の値のヘッダーを有効にしている場合はレスポンスも同じ形式にする必要がある
+		return w.albResponse(len(event.MultiValueHeaders) > 0), nil
+	case eventTypeAPIGatewayV2:
+		var event events.APIGatewayV2HTTPRequest
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+		req, err := newV2HTTPRequest(ctx, event)
+		if err != nil {
+			return nil, err
+		}
+		w := newEventResponseWriter()
+		e.ServeHTTP(w, req)
+		return w.v2Response(), nil
+	default:
+		var event events.APIGatewayProxyRequest
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+		return echolamda.New(e).ProxyWithContext(ctx, event)
+	}
+}
+
+// eventBody base64で符号化されている場合は復号する
+func eventBody(body string, isBase64Encoded bool) ([]byte, error) {
+	if !isBase64Encoded {
+		return []byte(body), nil
+	}
+	return base64.StdEncoding.DecodeString(body)
+}
+
+// newV2HTTPRequest HTTP APIとFunction URLのイベントをHTTPリクエストに変換する
+func newV2HTTPRequest(ctx context.Context, event events.APIGatewayV2HTTPRequest) (*http.Request, error) {
+	body, err := eventBody(event.Body, event.IsBase64Encoded)
+	if err != nil {
+		return nil, err
+	}
+	target := event.RawPath
+	if len(event.RawQueryString) > 0 {
+		target += "?" + event.RawQueryString
+	}
+	req, err := http.NewRequest(event.RequestContext.HTTP.Method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range event.Headers {
+		// 同じ名前のヘッダーはカンマで連結されている
+		req.Header.Set(name, value)
+	}
+	if len(event.Cookies) > 0 {
+		req.Header.Set("Cookie", strings.Join(event.Cookies, "; "))
+	}
+	req.Host = event.RequestContext.DomainName
+	req.RemoteAddr = event.RequestContext.HTTP.SourceIP
+	return req.WithContext(ctx), nil
+}
+
+// newALBHTTPRequest ALBのイベントをHTTPリクエストに変換する、クエリ文字列の値はURLエンコードされたまま渡される
+func newALBHTTPRequest(ctx context.Context, event events.ALBTargetGroupRequest) (*http.Request, error) {
+	body, err := eventBody(event.Body, event.IsBase64Encoded)
+	if err != nil {
+		return nil, err
+	}
+	var query []string
+	for name, value := range event.QueryStringParameters {
+		query = append(query, name+"="+value)
+	}
+	for name, values := range event.MultiValueQueryStringParameters {
+		for _, value := range values {
+			query = append(query, name+"="+value)
+		}
+	}
+	target := event.Path
+	if len(query) > 0 {
+		target += "?" + strings.Join(query, "&")
+	}
+	req, err := http.NewRequest(event.HTTPMethod, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range event.Headers {
+		req.Header.Set(name, value)
+	}
+	for name, values := range event.MultiValueHeaders {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	req.Host = req.Header.Get("Host")
+	return req.WithContext(ctx), nil
+}
+
+// eventResponseWriter echoのレスポンスを溜めてイベントの形式のレスポンスに変換する
+type eventResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newEventResponseWriter() *eventResponseWriter {
+	return &eventResponseWriter{header: make(http.Header)}
+}
+
+func (w *eventResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *eventResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.body.Write(b)
+}
+
+func (w *eventResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *eventResponseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// encodedBody UTF-8の文字列でない場合はbase64で符号化する
+func (w *eventResponseWriter) encodedBody() (string, bool) {
+	if utf8.Valid(w.body.Bytes()) {
+		return w.body.String(), false
+	}
+	return base64.StdEncoding.EncodeToString(w.body.Bytes()), true
+}
+
+// v2Response HTTP APIとFunction URLのレスポンス、Set-Cookieはcookiesで返却する
+func (w *eventResponseWriter) v2Response() events.APIGatewayV2HTTPResponse {
+	body, isBase64Encoded := w.encodedBody()
+	headers := make(map[string]string, len(w.header))
+	for name, values := range w.header {
+		if name == "Set-Cookie" {
+			continue
+		}
+		headers[name] = strings.Join(values, ",")
+	}
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode:      w.statusCode(),
+		Headers:         headers,
+		Body:            body,
+		IsBase64Encoded: isBase64Encoded,
+		Cookies:         w.header["Set-Cookie"],
+	}
+}
+
+// albResponse ALBのレスポンス、multiValueの場合はヘッダーを複数の値の形式で返却する
+func (w *eventResponseWriter) albResponse(multiValue bool) events.ALBTargetGroupResponse {
+	body, isBase64Encoded := w.encodedBody()
+	response := events.ALBTargetGroupResponse{
+		StatusCode:        w.statusCode(),
+		StatusDescription: fmt.Sprintf("%d %s", w.statusCode(), http.StatusText(w.statusCode())),
+		Body:              body,
+		IsBase64Encoded:   isBase64Encoded,
+	}
+	if multiValue {
+		response.MultiValueHeaders = make(map[string][]string, len(w.header))
+		for name, values := range w.header {
+			response.MultiValueHeaders[name] = values
+		}
+	} else {
+		response.Headers = make(map[string]string, len(w.header))
+		for name := range w.header {
+			response.Headers[name] = w.header.Get(name)
+		}
+	}
+	return response
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/akaishi-sandbox/sam-go/domain"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const apiGatewayEvent = `{
+  "resource": "/{proxy+}",
+  "path": "/search-items",
+  "httpMethod": "GET",
+  "headers": {"Accept": "application/json"},
+  "queryStringParameters": {"brand": "UNIQLO", "order": "min-max"},
+  "requestContext": {"requestId": "c6af9ac6-7b61-11e6-9a41-93e8deadbeef", "stage": "prod", "httpMethod": "GET"},
+  "body": null,
+  "isBase64Encoded": false
+}`
+
+const apiGatewayV2Event = `{
+  "version": "2.0",
+  "routeKey": "$default",
+  "rawPath": "/search-items",
+  "rawQueryString": "brand=UNIQLO&order=min-max",
+  "cookies": ["session=S001"],
+  "headers": {"accept": "application/json", "host": "api.example.com"},
+  "queryStringParameters": {"brand": "UNIQLO", "order": "min-max"},
+  "requestContext": {
+    "accountId": "123456789012",
+    "apiId": "api-id",
+    "domainName": "api.example.com",
+    "http": {"method": "GET", "path": "/search-items", "protocol": "HTTP/1.1", "sourceIp": "192.0.2.1", "userAgent": "agent"},
+    "requestId": "id",
+    "routeKey": "$default",
+    "stage": "$default"
+  },
+  "isBase64Encoded": false
+}`
+
+const functionURLEvent = `{
+  "version": "2.0",
+  "routeKey": "$default",
+  "rawPath": "/brands/1",
+  "rawQueryString": "",
+  "headers": {"host": "abcdefg.lambda-url.ap-northeast-1.on.aws"},
+  "requestContext": {
+    "accountId": "anonymous",
+    "apiId": "abcdefg",
+    "domainName": "abcdefg.lambda-url.ap-northeast-1.on.aws",
+    "domainPrefix": "abcdefg",
+    "http": {"method": "GET", "path": "/brands/1", "protocol": "HTTP/1.1", "sourceIp": "192.0.2.1", "userAgent": "agent"},
+    "requestId": "id",
+    "routeKey": "$default",
+    "stage": "$default"
+  },
+  "isBase64Encoded": false
+}`
+
+const albEvent = `{
+  "requestContext": {"elb": {"targetGroupArn": "arn:aws:elasticloadbalancing:ap-northeast-1:123456789012:targetgroup/lambda/abcdefg"}},
+  "httpMethod": "GET",
+  "path": "/search-items",
+  "queryStringParameters": {"keywords": "%E3%82%B7%E3%83%A3%E3%83%84", "order": "min-max"},
+  "headers": {"accept": "application/json", "host": "internal.example.com"},
+  "body": "",
+  "isBase64Encoded": false
+}`
+
+const albMultiValueEvent = `{
+  "requestContext": {"elb": {"targetGroupArn": "arn:aws:elasticloadbalancing:ap-northeast-1:123456789012:targetgroup/lambda/abcdefg"}},
+  "httpMethod": "GET",
+  "path": "/search-items",
+  "multiValueQueryStringParameters": {"order": ["cheap"]},
+  "multiValueHeaders": {"accept": ["application/json"], "host": ["internal.example.com"]},
+  "body": "",
+  "isBase64Encoded": false
+}`
+
+func TestDetectEventType(t *testing.T) {
+	testCase := func(payload string, expected string) {
+		t.Helper()
+		eventType, err := detectEventType(json.RawMessage(payload))
+		if err != nil || eventType != expected {
+			t.Errorf("event type error:%s %v", eventType, err)
+		}
+	}
+	testCase(apiGatewayEvent, eventTypeAPIGateway)
+	testCase(apiGatewayV2Event, eventTypeAPIGatewayV2)
+	testCase(functionURLEvent, eventTypeAPIGatewayV2)
+	testCase(albEvent, eventTypeALB)
+	testCase(albMultiValueEvent, eventTypeALB)
+}
+
+func searchResultTotal(t *testing.T, body string) int64 {
+	t.Helper()
+	var result domain.SearchResult
+	if err := json.Unmarshal([]byte(body), &result); err != nil {
+		t.Fatalf("response error:%v %s", err, body)
+	}
+	return result.Total
+}
+
+func TestProxyEventAPIGateway(t *testing.T) {
+	res, err := proxyEvent(context.Background(), newTestRouter(), json.RawMessage(apiGatewayEvent))
+	if err != nil {
+		t.Fatalf("proxy error:%v", err)
+	}
+	response, ok := res.(events.APIGatewayProxyResponse)
+	if !ok {
+		t.Fatalf("response type:%T", res)
+	}
+	if response.StatusCode != http.StatusOK || searchResultTotal(t, response.Body) != 2 {
+		t.Errorf("response error:%+v", response)
+	}
+}
+
+func TestProxyEventAPIGatewayV2(t *testing.T) {
+	res, err := proxyEvent(context.Background(), newTestRouter(), json.RawMessage(apiGatewayV2Event))
+	if err != nil {
+		t.Fatalf("proxy error:%v", err)
+	}
+	response, ok := res.(events.APIGatewayV2HTTPResponse)
+	if !ok {
+		t.Fatalf("response type:%T", res)
+	}
+	if response.StatusCode != http.StatusOK || response.IsBase64Encoded || searchResultTotal(t, response.Body) != 2 {
+		t.Errorf("response error:%+v", response)
+	}
+	if response.Headers["Content-Type"] != "application/json; charset=UTF-8" {
+		t.Errorf("response headers error:%v", response.Headers)
+	}
+}
+
+func TestProxyEventFunctionURL(t *testing.T) {
+	res, err := proxyEvent(context.Background(), newTestRouter(), json.RawMessage(functionURLEvent))
+	if err != nil {
+		t.Fatalf("proxy error:%v", err)
+	}
+	response, ok := res.(events.APIGatewayV2HTTPResponse)
+	if !ok {
+		t.Fatalf("response type:%T", res)
+	}
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("response error:%+v", response)
+	}
+}
+
+func TestProxyEventALB(t *testing.T) {
+	res, err := proxyEvent(context.Background(), newTestRouter(), json.RawMessage(albEvent))
+	if err != nil {
+		t.Fatalf("proxy error:%v", err)
+	}
+	response, ok := res.(events.ALBTargetGroupResponse)
+	if !ok {
+		t.Fatalf("response type:%T", res)
+	}
+	// クエリ文字列はURLデコードされてキーワードのシャツに一致する
+	if response.StatusCode != http.StatusOK || response.StatusDescription != "200 OK" || searchResultTotal(t, response.Body) != 3 {
+		t.Errorf("response error:%+v", response)
+	}
+	if len(response.Headers) == 0 || response.MultiValueHeaders != nil {
+		t.Errorf("response headers error:%+v", response)
+	}
+}
+
+func TestProxyEventALBMultiValue(t *testing.T) {
+	res, err := proxyEvent(context.Background(), newTestRouter(), json.RawMessage(albMultiValueEvent))
+	if err != nil {
+		t.Fatalf("proxy error:%v", err)
+	}
+	response, ok := res.(events.ALBTargetGroupResponse)
+	if !ok {
+		t.Fatalf("response type:%T", res)
+	}
+	if response.StatusCode != http.StatusBadRequest || response.StatusDescription != "400 Bad Request" {
+		t.Errorf("response error:%+v", response)
+	}
+	if len(response.MultiValueHeaders) == 0 || response.Headers != nil {
+		t.Errorf("response headers error:%+v", response)
+	}
+}
@@ -2,63 +2,129 @@ package main
 
 import (
 	"context"
-	"fmt"
-	"net/http"
+	"encoding/json"
+	"flag"
+	"log"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/akaishi-sandbox/sam-go/config"
 	"github.com/akaishi-sandbox/sam-go/infrastructure"
 	"github.com/akaishi-sandbox/sam-go/interfaces/controllers"
-	"github.com/aws/aws-lambda-go/events"
+	"github.com/akaishi-sandbox/sam-go/interfaces/database"
+	"github.com/akaishi-sandbox/sam-go/usecase"
 	"github.com/aws/aws-lambda-go/lambda"
-	echolamda "github.com/awslabs/aws-lambda-go-api-proxy/echo"
 	"github.com/getsentry/sentry-go"
 	"github.com/labstack/echo"
-	"github.com/labstack/echo/middleware"
 )
 
 var (
-	elasticsearchAddress = os.Getenv("ELASTICSEARCH_SERVICE_HOST_NAME")
+	// configFile 環境変数より先に読み込む設定ファイル、YAMLとJSONに対応する
+	configFile = os.Getenv("CONFIG_FILE")
+	// serverMode Lambdaではなく、server.addressで待ち受けるHTTPサーバーとして起動する
+	serverMode = os.Getenv("SERVER_MODE") == "1"
 )
 
-var echoLambda *echolamda.EchoLambda
+// appConfig 起動時に読み込んで検証した設定
+var appConfig config.Config
 
-// Handler is the main entry point for Lambda. Receives a proxy request and
-// returns a proxy response
-func Handler(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	if echoLambda == nil {
-		elasticHandler, err := infrastructure.NewElasticHandler(ctx, elasticsearchAddress)
-		if err != nil {
-			sentry.CaptureException(err)
-			return events.APIGatewayProxyResponse{
-				Body:       fmt.Sprintf("error:%v", err),
-				StatusCode: http.StatusInternalServerError,
-			}, err
+// searchConfig appConfigの検索の設定を変換したもの
+var searchConfig database.SearchConfig
+
+var router *echo.Echo
+
+var accessEventSink usecase.AccessEventSink
+
+// newSearchConfig 設定の検索の項目を検索の設定に変換する、既定値は設定で補っているためそのまま使う
+func newSearchConfig(search config.SearchConfig) database.SearchConfig {
+	sources := make(map[string]database.ClassificationSource, len(search.ClassificationSources))
+	for name, source := range search.ClassificationSources {
+		sources[name] = database.ClassificationSource{
+			Index:      source.Index,
+			Filters:    source.Filters,
+			Sort:       source.Sort,
+			Descending: source.Descending,
 		}
+	}
+	return database.SearchConfig{
+		Indices: database.Indices{
+			Items:        search.Indices.Items,
+			Categories:   search.Indices.Categories,
+			Brands:       search.Indices.Brands,
+			AccessEvents: search.Indices.AccessEvents,
+		},
+		DefaultLimit:          search.DefaultLimit,
+		ClassificationLimit:   search.ClassificationLimit,
+		PopularityHalfLife:    search.PopularityHalfLife,
+		TrendingHalfLife:      search.TrendingHalfLife,
+		RecommendFallbacks:    search.RecommendFallbacks,
+		ClassificationSources: sources,
+	}
+}
 
-		e := echo.New()
-		e.Use(middleware.Logger())
-		e.Use(middleware.Recover())
-		e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-			AllowOrigins: []string{"*"},
-			AllowMethods: []string{echo.GET, echo.HEAD, echo.PUT, echo.PATCH, echo.POST, echo.DELETE},
-		}))
-		e.Use(infrastructure.SentryechoNew(infrastructure.SentryechoOptions{}))
+// newItemController 設定されたElasticsearchに接続するコントローラーを作成する
+func newItemController(appConfig config.Config, searchConfig database.SearchConfig) (*controllers.ItemController, error) {
+	elasticHandler, err := infrastructure.NewElasticHandler(appConfig.Elasticsearch.URL)
+	if err != nil {
+		return nil, err
+	}
+	return controllers.NewItemController(elasticHandler, searchConfig, appConfig.AccessEvents.BufferSize), nil
+}
 
-		itemController := controllers.NewItemController(elasticHandler)
+// Handler is the main entry point for Lambda. Receives a REST API, HTTP API,
+// Function URL or ALB event and returns the response of the same format
+func Handler(ctx context.Context, payload json.RawMessage) (interface{}, error) {
+	if router == nil {
+		itemController, err := newItemController(appConfig, searchConfig)
+		if err != nil {
+			sentry.CaptureException(err)
+			return nil, err
+		}
+		accessEventSink = itemController.Interactor.AccessEventSink
 
-		e.GET("/search-items", itemController.Search)
-		e.GET("/recommend-items", itemController.Recommend)
-		e.GET("/classification-info", itemController.Classification)
-		e.GET("/access-info", itemController.Access)
-		echoLambda = echolamda.New(e)
+		router = newRouter(itemController, appConfig.Server)
 	}
 
-	return echoLambda.ProxyWithContext(ctx, req)
+	res, err := proxyEvent(ctx, router, payload)
+	// Lambdaは呼び出しの終了後に停止されるため、溜めたアクセスはレスポンスを返す前に書き込む
+	if flushErr := accessEventSink.Flush(ctx); flushErr != nil {
+		sentry.CaptureException(flushErr)
+	}
+	return res, err
 }
 
 func main() {
+	var serverAddress string
+	flag.BoolVar(&serverMode, "server", serverMode, "run as an HTTP server instead of Lambda")
+	flag.StringVar(&serverAddress, "addr", "", "listen address of the HTTP server (overrides server.address)")
+	flag.StringVar(&configFile, "config", configFile, "path of the YAML or JSON config file")
+	flag.Parse()
+
+	// 設定の誤りはコールドスタート時に検出して起動しない
+	var err error
+	appConfig, err = config.Load(configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	searchConfig = newSearchConfig(appConfig.Search)
+	if problems := searchConfig.Validate(); len(problems) > 0 {
+		log.Fatalf("invalid config: %s", strings.Join(problems, ", "))
+	}
+	if len(serverAddress) > 0 {
+		appConfig.Server.Address = serverAddress
+	}
+
 	sentry.Init(sentry.ClientOptions{
-		Dsn: os.Getenv("SENTRY_DSN"),
+		Dsn: appConfig.Sentry.DSN,
 	})
+	if serverMode {
+		if err := runServer(appConfig, searchConfig); err != nil {
+			sentry.CaptureException(err)
+			sentry.Flush(2 * time.Second)
+			log.Fatal(err)
+		}
+		return
+	}
 	lambda.Start(Handler)
 }
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/akaishi-sandbox/sam-go/config"
+)
+
+func TestNewSearchConfig(t *testing.T) {
+	search := config.Default().Search
+	search.Indices.Items = "items_v2"
+	search.TrendingHalfLife = 24 * time.Hour
+	search.ClassificationSources["colors"] = config.ClassificationSourceConfig{Filters: []string{"title"}, Sort: "title", Descending: true}
+	searchConfig := newSearchConfig(search)
+	if searchConfig.Indices.Items != "items_v2" || searchConfig.Indices.AccessEvents != "item_access_events" || searchConfig.DefaultLimit != 36 || searchConfig.TrendingHalfLife != 24*time.Hour {
+		t.Errorf("search config error:%+v", searchConfig)
+	}
+	if len(searchConfig.RecommendFallbacks) != 2 || searchConfig.RecommendFallbacks[0] != "same_gender" {
+		t.Errorf("recommend fallbacks error:%v", searchConfig.RecommendFallbacks)
+	}
+	if colors := searchConfig.ClassificationSources["colors"]; len(searchConfig.ClassificationSources) != 3 || colors.Sort != "title" || !colors.Descending {
+		t.Errorf("classification sources error:%+v", searchConfig.ClassificationSources)
+	}
+	// 既定値の設定はそのまま検索に使える
+	if problems := newSearchConfig(config.Default().Search).Validate(); len(problems) != 0 {
+		t.Errorf("validate error:%v", problems)
+	}
+}
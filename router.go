@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/akaishi-sandbox/sam-go/config"
+	"github.com/akaishi-sandbox/sam-go/infrastructure"
+	"github.com/akaishi-sandbox/sam-go/interfaces/controllers"
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+)
+
+// newRouter Lambdaとサーバーで共通のミドルウェアとルーティングを設定する
+func newRouter(itemController *controllers.ItemController, serverConfig config.ServerConfig) *echo.Echo {
+	e := echo.New()
+	e.HTTPErrorHandler = controllers.HTTPErrorHandler
+	e.Use(middleware.RequestID())
+	e.Use(middleware.Logger())
+	e.Use(middleware.Recover())
+	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOrigins: serverConfig.AllowOrigins,
+		AllowMethods: []string{echo.GET, echo.HEAD, echo.PUT, echo.PATCH, echo.POST, echo.DELETE},
+	}))
+	e.Use(infrastructure.SentryechoNew(infrastructure.SentryechoOptions{Repanic: true}))
+	e.Use(infrastructure.RequestTimeout(infrastructure.RequestTimeoutOptions{
+		Margin:  serverConfig.RequestTimeoutMargin,
+		Timeout: serverConfig.RequestTimeout,
+	}))
+
+	e.GET("/search-items", itemController.Search)
+	e.GET("/recommend-items", itemController.Recommend)
+	e.GET("/classification-info", itemController.Classification)
+	e.GET("/access-info", itemController.Access)
+	e.GET("/suggest", itemController.Suggest)
+	e.GET("/brands/:id", itemController.Brand)
+	return e
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/akaishi-sandbox/sam-go/config"
+	"github.com/akaishi-sandbox/sam-go/interfaces/controllers"
+	"github.com/akaishi-sandbox/sam-go/interfaces/database/databasetest"
+	"github.com/akaishi-sandbox/sam-go/usecase"
+	"github.com/labstack/echo"
+)
+
+func newTestRouter() *echo.Echo {
+	itemRepository := databasetest.NewItemRepository()
+	return newRouter(&controllers.ItemController{
+		Interactor: usecase.ItemInteractor{
+			ItemRepository:  itemRepository,
+			AccessEventSink: usecase.NewBufferedAccessEventSink(itemRepository, 100),
+		},
+		DefaultLimit:        databasetest.SearchConfig.DefaultLimit,
+		ClassificationLimit: databasetest.SearchConfig.ClassificationLimit,
+	}, config.Default().Server)
+}
+
+func TestRouter(t *testing.T) {
+	router := newTestRouter()
+	testCase := func(target string, status int) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != status {
+			t.Errorf("status error:%s %d %s", target, rec.Code, rec.Body.String())
+		}
+		return rec
+	}
+	testCase("/search-items?brand=UNIQLO", http.StatusOK)
+	testCase("/search-items?offset=9990", http.StatusBadRequest)
+	testCase("/recommend-items?item_id=A001", http.StatusOK)
+	testCase("/classification-info?index=categories", http.StatusOK)
+	testCase("/access-info?item_id=A001", http.StatusAccepted)
+
+	// panicはSentryに送信した後にRecoverで500のレスポンスにする
+	router.GET("/panic", func(c echo.Context) error {
+		panic("unexpected")
+	})
+	testCase("/panic", http.StatusInternalServerError)
+
+	rec := testCase("/unknown", http.StatusNotFound)
+	var response controllers.ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("response error:%v", err)
+	}
+	if response.Code != controllers.ErrorCodeNotFound || len(response.RequestID) == 0 {
+		t.Errorf("not found response error:%s", rec.Body.String())
+	}
+}
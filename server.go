@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/akaishi-sandbox/sam-go/config"
+	"github.com/akaishi-sandbox/sam-go/interfaces/database"
+	"github.com/akaishi-sandbox/sam-go/usecase"
+	"github.com/getsentry/sentry-go"
+)
+
+// flushAccessEvents リクエストとは別に溜めたアクセスを書き込む
+func flushAccessEvents(sink usecase.AccessEventSink, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return sink.Flush(ctx)
+}
+
+// runServer Lambdaを使わずにHTTPサーバーとして起動する、SIGTERMを受け取った場合は処理中のリクエストを待って終了する
+func runServer(appConfig config.Config, searchConfig database.SearchConfig) error {
+	itemController, err := newItemController(appConfig, searchConfig)
+	if err != nil {
+		return err
+	}
+	sink := itemController.Interactor.AccessEventSink
+	serverConfig := appConfig.Server
+
+	e := newRouter(itemController, serverConfig)
+	e.HideBanner = true
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- e.Start(serverConfig.Address)
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(quit)
+
+	// Lambdaと違い呼び出し毎に書き込まないため、アクセスが少ない場合も一定の間隔で書き込む
+	ticker := time.NewTicker(appConfig.AccessEvents.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-serverErr:
+			if err == http.ErrServerClosed {
+				return nil
+			}
+			return err
+		case <-ticker.C:
+			if err := flushAccessEvents(sink, serverConfig.RequestTimeout); err != nil {
+				sentry.CaptureException(err)
+			}
+		case <-quit:
+			ctx, cancel := context.WithTimeout(context.Background(), serverConfig.ShutdownTimeout)
+			defer cancel()
+			if err := e.Shutdown(ctx); err != nil {
+				return err
+			}
+			return flushAccessEvents(sink, serverConfig.RequestTimeout)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+
+	"github.com/akaishi-sandbox/sam-go/domain"
+)
+
+// AccessEventSink interface
+// 商品へのアクセスを記録する、記録したアクセスはFlushで永続化する
+type AccessEventSink interface {
+	Record(ctx context.Context, event *domain.AccessEvent) error
+	Flush(ctx context.Context) error
+}
+
+// BufferedAccessEventSink プロセス内にアクセスを溜めて、Flush時に商品毎に集約してまとめて書き込む
+// セッションの識別子があるアクセスは一緒に閲覧された商品を集計するため集約せずに個別に書き込む
+type BufferedAccessEventSink struct {
+	ItemRepository ItemRepository
+	// MaxEvents 溜めたアクセスがこの件数に達した場合はRecord時に書き込む
+	MaxEvents int
+	mutex     sync.Mutex
+	itemIDs   []string
+	events    map[string]*domain.AccessEvent
+	count     int
+	// sessionEvents セッションの識別子があるアクセス
+	sessionEvents []*domain.AccessEvent
+}
+
+// NewBufferedAccessEventSink instance
+func NewBufferedAccessEventSink(itemRepository ItemRepository, maxEvents int) *BufferedAccessEventSink {
+	return &BufferedAccessEventSink{
+		ItemRepository: itemRepository,
+		MaxEvents:      maxEvents,
+		events:         make(map[string]*domain.AccessEvent),
+	}
+}
+
+// Record function
+func (sink *BufferedAccessEventSink) Record(ctx context.Context, event *domain.AccessEvent) error {
+	sink.mutex.Lock()
+	if buffered, ok := sink.events[event.ItemID]; ok {
+		buffered.Count += event.Count
+		if event.AccessedAt.After(buffered.AccessedAt) {
+			buffered.AccessedAt = event.AccessedAt
+		}
+	} else {
+		copied := *event
+		copied.SessionID = ""
+		sink.events[event.ItemID] = &copied
+		sink.itemIDs = append(sink.itemIDs, event.ItemID)
+	}
+	if len(event.SessionID) > 0 {
+		copied := *event
+		sink.sessionEvents = append(sink.sessionEvents, &copied)
+	}
+	sink.count++
+	full := sink.MaxEvents > 0 && sink.count >= sink.MaxEvents
+	sink.mutex.Unlock()
+
+	if full {
+		return sink.Flush(ctx)
+	}
+	return nil
+}
+
+// Flush function
+// 書き込みに失敗したアクセスは二重に加算しないよう再送せずに破棄する
+func (sink *BufferedAccessEventSink) Flush(ctx context.Context) error {
+	sink.mutex.Lock()
+	events := make([]*domain.AccessEvent, 0, len(sink.itemIDs))
+	for _, itemID := range sink.itemIDs {
+		events = append(events, sink.events[itemID])
+	}
+	sessionEvents := sink.sessionEvents
+	sink.itemIDs = nil
+	sink.events = make(map[string]*domain.AccessEvent)
+	sink.sessionEvents = nil
+	sink.count = 0
+	sink.mutex.Unlock()
+
+	var err error
+	if len(events) > 0 {
+		err = sink.ItemRepository.RecordAccess(ctx, events)
+	}
+	// アクセス回数の更新に失敗してもアクセスの記録は書き込む
+	if len(sessionEvents) > 0 {
+		if recordErr := sink.ItemRepository.RecordAccessEvents(ctx, sessionEvents); err == nil {
+			err = recordErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/akaishi-sandbox/sam-go/domain"
+	"github.com/akaishi-sandbox/sam-go/interfaces/database/databasetest"
+)
+
+func TestBufferedAccessEventSink(t *testing.T) {
+	repo := databasetest.NewItemRepository()
+	sink := NewBufferedAccessEventSink(repo, 100)
+
+	accessedAt := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i, itemID := range []string{"A001", "A002", "A001", "A001"} {
+		if err := sink.Record(context.Background(), &domain.AccessEvent{ItemID: itemID, Count: 1, AccessedAt: accessedAt.Add(time.Duration(i) * time.Minute)}); err != nil {
+			t.Fatalf("record error:%v", err)
+		}
+	}
+	if repo.Items[0].AccessCounter != 0 {
+		t.Errorf("recorded before flush:%+v", repo.Items[0])
+	}
+	if err := sink.Flush(context.Background()); err != nil {
+		t.Fatalf("flush error:%v", err)
+	}
+	if item := repo.Items[0]; item.AccessCounter != 3 || !item.LastAccessedAt.Equal(accessedAt.Add(3*time.Minute)) {
+		t.Errorf("access counter error:%+v", item)
+	}
+	if item := repo.Items[1]; item.AccessCounter != 1 {
+		t.Errorf("access counter error:%+v", item)
+	}
+
+	// 溜めたアクセスは書き込み後に空になる
+	if err := sink.Flush(context.Background()); err != nil {
+		t.Fatalf("flush error:%v", err)
+	}
+	if item := repo.Items[0]; item.AccessCounter != 3 {
+		t.Errorf("flushed twice:%+v", item)
+	}
+}
+
+func TestBufferedAccessEventSinkSession(t *testing.T) {
+	repo := databasetest.NewItemRepository()
+	sink := NewBufferedAccessEventSink(repo, 100)
+	for _, event := range []*domain.AccessEvent{
+		{ItemID: "A001", Count: 1, AccessedAt: time.Now(), SessionID: "S001"},
+		{ItemID: "A002", Count: 1, AccessedAt: time.Now(), SessionID: "S001"},
+		{ItemID: "A001", Count: 1, AccessedAt: time.Now()},
+	} {
+		if err := sink.Record(context.Background(), event); err != nil {
+			t.Fatalf("record error:%v", err)
+		}
+	}
+	if err := sink.Flush(context.Background()); err != nil {
+		t.Fatalf("flush error:%v", err)
+	}
+	// アクセス回数はセッションの有無に関わらず集約し、セッションのあるアクセスは個別に記録する
+	if item := repo.Items[0]; item.AccessCounter != 2 {
+		t.Errorf("access counter error:%+v", item)
+	}
+	if len(repo.AccessEvents) != 2 || repo.AccessEvents[0].ItemID != "A001" || repo.AccessEvents[1].SessionID != "S001" {
+		t.Errorf("access events error:%+v", repo.AccessEvents)
+	}
+}
+
+func TestBufferedAccessEventSinkMaxEvents(t *testing.T) {
+	repo := databasetest.NewItemRepository()
+	sink := NewBufferedAccessEventSink(repo, 2)
+	for i := 0; i < 2; i++ {
+		if err := sink.Record(context.Background(), &domain.AccessEvent{ItemID: "A001", Count: 1, AccessedAt: time.Now()}); err != nil {
+			t.Fatalf("record error:%v", err)
+		}
+	}
+	if item := repo.Items[0]; item.AccessCounter != 2 {
+		t.Errorf("not flushed at max events:%+v", item)
+	}
+}
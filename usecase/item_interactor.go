@@ -1,64 +1,81 @@
 package usecase
 
 import (
-	elastic "github.com/olivere/elastic/v7"
+	"context"
+	"time"
+
+	"github.com/akaishi-sandbox/sam-go/domain"
 )
 
 // ItemInteractor struct
 type ItemInteractor struct {
-	ItemRepository ItemRepository
+	ItemRepository  ItemRepository
+	AccessEventSink AccessEventSink
 }
 
 // Search function
-func (interactor *ItemInteractor) Search(q map[string]string) (interface{}, error) {
-	searchResult, err := interactor.ItemRepository.Search(q)
+func (interactor *ItemInteractor) Search(ctx context.Context, request *SearchRequest) (interface{}, error) {
+	searchResult, err := interactor.ItemRepository.Search(ctx, request.Parameters())
 	if err != nil {
 		return nil, err
 	}
-	return struct {
-		Total int64                `json:"total"`
-		Items []*elastic.SearchHit `json:"items"`
-	}{
-		Total: searchResult.TotalHits(),
-		Items: searchResult.Hits.Hits,
-	}, nil
+	searchResult.Version = domain.ResultVersion
+	return searchResult, nil
 }
 
 // Recommend function
-func (interactor *ItemInteractor) Recommend(q map[string]string) (interface{}, error) {
-	searchResult, err := interactor.ItemRepository.Recommend(q)
+func (interactor *ItemInteractor) Recommend(ctx context.Context, request *RecommendRequest) (interface{}, error) {
+	searchResult, err := interactor.ItemRepository.Recommend(ctx, request.Parameters())
 	if err != nil {
 		return nil, err
 	}
-	return struct {
-		Total int64                `json:"total"`
-		Items []*elastic.SearchHit `json:"items"`
-	}{
-		Total: searchResult.TotalHits(),
-		Items: searchResult.Hits.Hits,
-	}, nil
+	searchResult.Version = domain.ResultVersion
+	return searchResult, nil
 }
 
 // Classification function
-func (interactor *ItemInteractor) Classification(q map[string]string) (interface{}, error) {
-	searchResult, err := interactor.ItemRepository.Classification(q)
+func (interactor *ItemInteractor) Classification(ctx context.Context, request *ClassificationRequest) (interface{}, error) {
+	searchResult, err := interactor.ItemRepository.Classification(ctx, request.Parameters())
 	if err != nil {
 		return nil, err
 	}
-	return struct {
-		Total int64                `json:"total"`
-		Hits  []*elastic.SearchHit `json:"hits"`
-	}{
-		Total: searchResult.TotalHits(),
-		Hits:  searchResult.Hits.Hits,
-	}, nil
+	searchResult.Version = domain.ResultVersion
+	return searchResult, nil
 }
 
-// AccessInfo function
-func (interactor *ItemInteractor) AccessInfo(q map[string]string) (interface{}, error) {
-	updateItem, err := interactor.ItemRepository.AccessInfo(q)
+// Suggest function
+func (interactor *ItemInteractor) Suggest(ctx context.Context, request *SuggestRequest) (interface{}, error) {
+	suggestions, err := interactor.ItemRepository.Suggest(ctx, request.Parameters())
+	if err != nil {
+		return nil, err
+	}
+	suggestions.Version = domain.ResultVersion
+	return suggestions, nil
+}
+
+// Brand function
+func (interactor *ItemInteractor) Brand(ctx context.Context, request *BrandRequest) (interface{}, error) {
+	brandDetail, err := interactor.ItemRepository.Brand(ctx, request.Parameters())
 	if err != nil {
 		return nil, err
 	}
-	return updateItem, nil
+	brandDetail.Version = domain.ResultVersion
+	return brandDetail, nil
+}
+
+// AccessInfo function
+func (interactor *ItemInteractor) AccessInfo(ctx context.Context, request *AccessRequest) (interface{}, error) {
+	event := &domain.AccessEvent{
+		ItemID:     request.ItemID,
+		Count:      1,
+		AccessedAt: time.Now(),
+		SessionID:  request.SessionID,
+	}
+	if len(event.SessionID) == 0 {
+		event.SessionID = request.UserID
+	}
+	if err := interactor.AccessEventSink.Record(ctx, event); err != nil {
+		return nil, err
+	}
+	return event, nil
 }
@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/akaishi-sandbox/sam-go/domain"
+	"github.com/akaishi-sandbox/sam-go/interfaces/database"
+	"github.com/akaishi-sandbox/sam-go/interfaces/database/databasetest"
+)
+
+var _ ItemRepository = &database.ItemRepository{}
+var _ ItemRepository = &database.MemoryItemRepository{}
+
+func newTestItemInteractor() *ItemInteractor {
+	return &ItemInteractor{
+		ItemRepository: databasetest.NewItemRepository(),
+	}
+}
+
+func TestItemInteractorSearch(t *testing.T) {
+	interactor := newTestItemInteractor()
+	result, err := interactor.Search(context.Background(), &SearchRequest{Brands: []string{"UNIQLO"}, Order: "min-max"})
+	if err != nil {
+		t.Fatalf("search error:%v", err)
+	}
+	searchResult, ok := result.(*domain.SearchResult)
+	if !ok {
+		t.Fatalf("search result type:%T", result)
+	}
+	if searchResult.Version != domain.ResultVersion || searchResult.Total != 2 || searchResult.Items[0].ItemID != "A001" {
+		t.Errorf("search result error:%+v", searchResult)
+	}
+}
+
+func TestItemInteractorRecommend(t *testing.T) {
+	interactor := newTestItemInteractor()
+	result, err := interactor.Recommend(context.Background(), &RecommendRequest{ItemIDs: []string{"A001"}})
+	if err != nil {
+		t.Fatalf("recommend error:%v", err)
+	}
+	searchResult, ok := result.(*domain.SearchResult)
+	if !ok {
+		t.Fatalf("recommend result type:%T", result)
+	}
+	if searchResult.Version != domain.ResultVersion || searchResult.Total != 1 || searchResult.Items[0].ItemID != "A002" {
+		t.Errorf("recommend result error:%+v", searchResult)
+	}
+}
+
+func TestItemInteractorClassification(t *testing.T) {
+	interactor := newTestItemInteractor()
+	result, err := interactor.Classification(context.Background(), &ClassificationRequest{Index: "categories"})
+	if err != nil {
+		t.Fatalf("classification error:%v", err)
+	}
+	classificationResult, ok := result.(*domain.ClassificationResult)
+	if !ok {
+		t.Fatalf("classification result type:%T", result)
+	}
+	if classificationResult.Version != domain.ResultVersion || classificationResult.Total != 2 {
+		t.Errorf("classification result error:%+v", classificationResult)
+	}
+}
+
+func TestItemInteractorSuggest(t *testing.T) {
+	interactor := newTestItemInteractor()
+	result, err := interactor.Suggest(context.Background(), &SuggestRequest{Keywords: "シャツ"})
+	if err != nil {
+		t.Fatalf("suggest error:%v", err)
+	}
+	suggestions, ok := result.(*domain.Suggestions)
+	if !ok {
+		t.Fatalf("suggest result type:%T", result)
+	}
+	if suggestions.Version != domain.ResultVersion {
+		t.Errorf("suggest result error:%+v", suggestions)
+	}
+}
@@ -1,14 +1,18 @@
 package usecase
 
 import (
+	"context"
+
 	"github.com/akaishi-sandbox/sam-go/domain"
-	elastic "github.com/olivere/elastic/v7"
 )
 
 // ItemRepository interface
 type ItemRepository interface {
-	Search(q map[string]string) (*elastic.SearchResult, error)
-	Recommend(q map[string]string) (*elastic.SearchResult, error)
-	Classification(q map[string]string) (*elastic.SearchResult, error)
-	AccessInfo(q map[string]string) (*domain.Item, error)
+	Search(ctx context.Context, q map[string]string) (*domain.SearchResult, error)
+	Recommend(ctx context.Context, q map[string]string) (*domain.SearchResult, error)
+	Classification(ctx context.Context, q map[string]string) (*domain.ClassificationResult, error)
+	Suggest(ctx context.Context, q map[string]string) (*domain.Suggestions, error)
+	Brand(ctx context.Context, q map[string]string) (*domain.BrandDetail, error)
+	RecordAccess(ctx context.Context, events []*domain.AccessEvent) error
+	RecordAccessEvents(ctx context.Context, events []*domain.AccessEvent) error
 }
@@ -0,0 +1,502 @@
+package usecase
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/akaishi-sandbox/sam-go/domain"
+)
+
+// maxResultWindow offsetとlimitの合計の上限、Elasticsearchのindex.max_result_windowに合わせる
+const maxResultWindow = 10000
+
+// 一度に返却できる件数の上限
+const (
+	maxSearchLimit         = 100
+	maxClassificationLimit = 1000
+	maxSuggestLimit        = 50
+)
+
+// discountFlags discount_flagに指定できる値
+var discountFlags = domain.Choices{"0", "1"}
+
+// parameterBinder クエリ文字列を型に変換し、不正なパラメータを全て記録する
+type parameterBinder struct {
+	parameters map[string]string
+	fields     []domain.FieldError
+}
+
+func newParameterBinder(parameters map[string]string) *parameterBinder {
+	return &parameterBinder{parameters: parameters}
+}
+
+func (b *parameterBinder) invalid(name, message string) {
+	b.fields = append(b.fields, domain.FieldError{Field: name, Message: message})
+}
+
+func (b *parameterBinder) string(name string) string {
+	return b.parameters[name]
+}
+
+func (b *parameterBinder) strings(name string) []string {
+	if value := b.parameters[name]; len(value) > 0 {
+		return strings.Split(value, ",")
+	}
+	return nil
+}
+
+func (b *parameterBinder) bool(name string) bool {
+	value := b.parameters[name]
+	if len(value) == 0 {
+		return false
+	}
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		b.invalid(name, "must be a boolean")
+		return false
+	}
+	return v
+}
+
+func (b *parameterBinder) int(name string) *int {
+	value := b.parameters[name]
+	if len(value) == 0 {
+		return nil
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		b.invalid(name, "must be an integer")
+		return nil
+	}
+	return &v
+}
+
+func (b *parameterBinder) float(name string) *float64 {
+	value := b.parameters[name]
+	if len(value) == 0 {
+		return nil
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		b.invalid(name, "must be a number")
+		return nil
+	}
+	return &v
+}
+
+func (b *parameterBinder) required(name, value string) {
+	if len(value) == 0 {
+		b.invalid(name, "is required")
+	}
+}
+
+func (b *parameterBinder) oneOf(name, value string, values domain.Choices) {
+	if len(value) > 0 && !values.Contains(value) {
+		b.invalid(name, "must be one of "+strings.Join(values, ", "))
+	}
+}
+
+func (b *parameterBinder) eachOneOf(name string, value []string, values domain.Choices) {
+	for _, v := range value {
+		if !values.Contains(v) {
+			b.invalid(name, "must be one of "+strings.Join(values, ", "))
+			return
+		}
+	}
+}
+
+func (b *parameterBinder) minInt(name string, value *int, min int) {
+	if value != nil && *value < min {
+		b.invalid(name, "must be greater than or equal to "+strconv.Itoa(min))
+	}
+}
+
+func (b *parameterBinder) positive(name string, value *float64) {
+	if value != nil && *value <= 0 {
+		b.invalid(name, "must be greater than 0")
+	}
+}
+
+// paging offsetとlimitの範囲を検証する、limitを指定しない場合はdefaultLimitの件数でoffsetとの合計を検証する
+func (b *parameterBinder) paging(offset, limit *int, defaultLimit, maxLimit int) {
+	b.minInt("offset", offset, 0)
+	if limit != nil && (*limit < 1 || *limit > maxLimit) {
+		b.invalid("limit", "must be between 1 and "+strconv.Itoa(maxLimit))
+	}
+	size := defaultLimit
+	if limit != nil {
+		size = *limit
+	}
+	if offset != nil && *offset >= 0 && *offset+size > maxResultWindow {
+		b.invalid("offset", "offset + limit must be less than or equal to "+strconv.Itoa(maxResultWindow))
+	}
+}
+
+// err 不正なパラメータがある場合はまとめてValidationErrorとして返却する
+func (b *parameterBinder) err() error {
+	if len(b.fields) == 0 {
+		return nil
+	}
+	return &domain.ValidationError{Message: "invalid parameters", Fields: b.fields}
+}
+
+// requestParameters リポジトリに渡すパラメータを組み立てる、指定されていない値は含めない
+type requestParameters map[string]string
+
+func (p requestParameters) string(name, value string) {
+	if len(value) > 0 {
+		p[name] = value
+	}
+}
+
+func (p requestParameters) strings(name string, value []string) {
+	if len(value) > 0 {
+		p[name] = strings.Join(value, ",")
+	}
+}
+
+func (p requestParameters) bool(name string, value bool) {
+	if value {
+		p[name] = "1"
+	}
+}
+
+func (p requestParameters) int(name string, value *int) {
+	if value != nil {
+		p[name] = strconv.Itoa(*value)
+	}
+}
+
+func (p requestParameters) float(name string, value *float64) {
+	if value != nil {
+		p[name] = strconv.FormatFloat(*value, 'f', -1, 64)
+	}
+}
+
+// SearchRequest 商品検索のパラメータ
+type SearchRequest struct {
+	Keywords       string
+	ItemIDs        []string
+	Genders        []string
+	Brands         []string
+	Categories     []string
+	DiscountFlags  []string
+	MinPrice       *int
+	MaxPrice       *int
+	MinBmi         *float64
+	MaxBmi         *float64
+	ExcludeExpired bool
+	Highlight      bool
+	Facets         []string
+	Order          string
+	Cursor         string
+	Offset         *int
+	Limit          *int
+	Diversify      string
+	DiversifyLimit *int
+}
+
+// NewSearchRequest クエリ文字列から商品検索のパラメータを作成する、defaultLimitはlimitを指定しない場合の件数
+func NewSearchRequest(parameters map[string]string, defaultLimit int) (*SearchRequest, error) {
+	b := newParameterBinder(parameters)
+	request := &SearchRequest{
+		Keywords:       b.string("keywords"),
+		ItemIDs:        b.strings("item_id"),
+		Genders:        b.strings("gender"),
+		Brands:         b.strings("brand"),
+		Categories:     b.strings("category"),
+		DiscountFlags:  b.strings("discount_flag"),
+		MinPrice:       b.int("min_price"),
+		MaxPrice:       b.int("max_price"),
+		MinBmi:         b.float("min_bmi"),
+		MaxBmi:         b.float("max_bmi"),
+		ExcludeExpired: b.bool("exclude_expired"),
+		Highlight:      b.bool("highlight"),
+		Facets:         b.strings("facets"),
+		Order:          b.string("order"),
+		Cursor:         b.string("cursor"),
+		Offset:         b.int("offset"),
+		Limit:          b.int("limit"),
+		Diversify:      b.string("diversify"),
+		DiversifyLimit: b.int("diversify_limit"),
+	}
+
+	// キーワードの構文エラーも他のパラメータと合わせて返却する
+	if len(request.Keywords) > 0 {
+		if _, err := domain.ParseKeywords(request.Keywords); err != nil {
+			b.invalid("keywords", err.Error())
+		}
+	}
+	b.eachOneOf("discount_flag", request.DiscountFlags, discountFlags)
+	b.minInt("min_price", request.MinPrice, 0)
+	b.minInt("max_price", request.MaxPrice, 0)
+	if request.MinPrice != nil && request.MaxPrice != nil && *request.MinPrice > *request.MaxPrice {
+		b.invalid("min_price", "must be less than or equal to max_price")
+	}
+	b.positive("min_bmi", request.MinBmi)
+	b.positive("max_bmi", request.MaxBmi)
+	if request.MinBmi != nil && request.MaxBmi != nil && *request.MinBmi > *request.MaxBmi {
+		b.invalid("min_bmi", "must be less than or equal to max_bmi")
+	}
+	// facetsは1で全て、0で返却しない
+	if len(request.Facets) != 1 || (request.Facets[0] != "0" && request.Facets[0] != "1") {
+		b.eachOneOf("facets", request.Facets, domain.FacetNames)
+	}
+	b.oneOf("order", request.Order, domain.SearchOrders)
+	if len(request.Cursor) > 0 {
+		if _, err := domain.ParseCursor(request.Cursor); err != nil {
+			b.invalid("cursor", "is invalid")
+		}
+	}
+	b.paging(request.Offset, request.Limit, defaultLimit, maxSearchLimit)
+	b.oneOf("diversify", request.Diversify, domain.DiversifyFields)
+	if len(request.Diversify) > 0 && len(request.Cursor) > 0 {
+		b.invalid("diversify", "can not be used with cursor")
+	}
+	b.minInt("diversify_limit", request.DiversifyLimit, 1)
+
+	if err := b.err(); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// Parameters リポジトリに渡すパラメータ
+func (request *SearchRequest) Parameters() map[string]string {
+	p := requestParameters{}
+	p.string("keywords", request.Keywords)
+	p.strings("item_id", request.ItemIDs)
+	p.strings("gender", request.Genders)
+	p.strings("brand", request.Brands)
+	p.strings("category", request.Categories)
+	p.strings("discount_flag", request.DiscountFlags)
+	p.int("min_price", request.MinPrice)
+	p.int("max_price", request.MaxPrice)
+	p.float("min_bmi", request.MinBmi)
+	p.float("max_bmi", request.MaxBmi)
+	p.bool("exclude_expired", request.ExcludeExpired)
+	p.bool("highlight", request.Highlight)
+	p.strings("facets", request.Facets)
+	p.string("order", request.Order)
+	p.string("cursor", request.Cursor)
+	p.int("offset", request.Offset)
+	p.int("limit", request.Limit)
+	p.string("diversify", request.Diversify)
+	p.int("diversify_limit", request.DiversifyLimit)
+	return p
+}
+
+// RecommendRequest おすすめ商品のパラメータ
+type RecommendRequest struct {
+	ItemIDs        []string
+	Brands         []string
+	Strategy       string
+	Fallbacks      []string
+	Strict         bool
+	Bmi            *float64
+	Height         *float64
+	Weight         *float64
+	Offset         *int
+	Limit          *int
+	Diversify      string
+	DiversifyLimit *int
+}
+
+// NewRecommendRequest クエリ文字列からおすすめ商品のパラメータを作成する、defaultLimitはlimitを指定しない場合の件数
+func NewRecommendRequest(parameters map[string]string, defaultLimit int) (*RecommendRequest, error) {
+	b := newParameterBinder(parameters)
+	request := &RecommendRequest{
+		ItemIDs:        b.strings("item_id"),
+		Brands:         b.strings("brand"),
+		Strategy:       b.string("strategy"),
+		Fallbacks:      b.strings("fallback"),
+		Strict:         b.bool("strict"),
+		Bmi:            b.float("bmi"),
+		Height:         b.float("height"),
+		Weight:         b.float("weight"),
+		Offset:         b.int("offset"),
+		Limit:          b.int("limit"),
+		Diversify:      b.string("diversify"),
+		DiversifyLimit: b.int("diversify_limit"),
+	}
+
+	if len(request.ItemIDs) == 0 {
+		b.invalid("item_id", "is required")
+	}
+	b.oneOf("strategy", request.Strategy, domain.RecommendStrategies)
+	// fallbackはnoneで代わりの方法を使わない
+	if len(request.Fallbacks) != 1 || request.Fallbacks[0] != "none" {
+		b.eachOneOf("fallback", request.Fallbacks, domain.RecommendFallbacks)
+	}
+	b.positive("bmi", request.Bmi)
+	b.positive("height", request.Height)
+	b.positive("weight", request.Weight)
+	if request.Bmi == nil && (request.Height == nil) != (request.Weight == nil) {
+		if request.Height == nil {
+			b.invalid("height", "is required with weight")
+		} else {
+			b.invalid("weight", "is required with height")
+		}
+	}
+	b.paging(request.Offset, request.Limit, defaultLimit, maxSearchLimit)
+	b.oneOf("diversify", request.Diversify, domain.DiversifyFields)
+	b.minInt("diversify_limit", request.DiversifyLimit, 1)
+
+	if err := b.err(); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// Parameters リポジトリに渡すパラメータ
+func (request *RecommendRequest) Parameters() map[string]string {
+	p := requestParameters{}
+	p.strings("item_id", request.ItemIDs)
+	p.strings("brand", request.Brands)
+	p.string("strategy", request.Strategy)
+	p.strings("fallback", request.Fallbacks)
+	p.bool("strict", request.Strict)
+	p.float("bmi", request.Bmi)
+	p.float("height", request.Height)
+	p.float("weight", request.Weight)
+	p.int("offset", request.Offset)
+	p.int("limit", request.Limit)
+	p.string("diversify", request.Diversify)
+	p.int("diversify_limit", request.DiversifyLimit)
+	return p
+}
+
+// ClassificationRequest 分類一覧のパラメータ、Filtersに指定できる項目は分類毎の設定による
+type ClassificationRequest struct {
+	Index   string
+	Mode    string
+	Offset  *int
+	Limit   *int
+	Filters map[string]string
+}
+
+// NewClassificationRequest クエリ文字列から分類一覧のパラメータを作成する、defaultLimitはlimitを指定しない場合の件数
+func NewClassificationRequest(parameters map[string]string, defaultLimit int) (*ClassificationRequest, error) {
+	b := newParameterBinder(parameters)
+	request := &ClassificationRequest{
+		Index:   b.string("index"),
+		Mode:    b.string("mode"),
+		Offset:  b.int("offset"),
+		Limit:   b.int("limit"),
+		Filters: map[string]string{},
+	}
+	for name, value := range parameters {
+		switch name {
+		case "index", "mode", "offset", "limit":
+		default:
+			request.Filters[name] = value
+		}
+	}
+
+	b.required("index", request.Index)
+	b.oneOf("mode", request.Mode, domain.ClassificationModes)
+	b.paging(request.Offset, request.Limit, defaultLimit, maxClassificationLimit)
+
+	if err := b.err(); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// Parameters リポジトリに渡すパラメータ
+func (request *ClassificationRequest) Parameters() map[string]string {
+	p := requestParameters{}
+	for name, value := range request.Filters {
+		p[name] = value
+	}
+	p.string("index", request.Index)
+	p.string("mode", request.Mode)
+	p.int("offset", request.Offset)
+	p.int("limit", request.Limit)
+	return p
+}
+
+// SuggestRequest 入力中のキーワードの候補のパラメータ
+type SuggestRequest struct {
+	Keywords string
+	Genders  []string
+	Limit    *int
+}
+
+// NewSuggestRequest クエリ文字列から入力中のキーワードの候補のパラメータを作成する
+func NewSuggestRequest(parameters map[string]string) (*SuggestRequest, error) {
+	b := newParameterBinder(parameters)
+	request := &SuggestRequest{
+		Keywords: b.string("keywords"),
+		Genders:  b.strings("gender"),
+		Limit:    b.int("limit"),
+	}
+
+	b.required("keywords", strings.TrimSpace(request.Keywords))
+	b.paging(nil, request.Limit, 0, maxSuggestLimit)
+
+	if err := b.err(); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// Parameters リポジトリに渡すパラメータ
+func (request *SuggestRequest) Parameters() map[string]string {
+	p := requestParameters{}
+	p.string("keywords", request.Keywords)
+	p.strings("gender", request.Genders)
+	p.int("limit", request.Limit)
+	return p
+}
+
+// BrandRequest ブランドの詳細のパラメータ
+type BrandRequest struct {
+	ID string
+}
+
+// NewBrandRequest パスパラメータからブランドの詳細のパラメータを作成する
+func NewBrandRequest(parameters map[string]string) (*BrandRequest, error) {
+	b := newParameterBinder(parameters)
+	request := &BrandRequest{
+		ID: b.string("id"),
+	}
+
+	b.required("id", request.ID)
+
+	if err := b.err(); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// Parameters リポジトリに渡すパラメータ
+func (request *BrandRequest) Parameters() map[string]string {
+	p := requestParameters{}
+	p.string("id", request.ID)
+	return p
+}
+
+// AccessRequest 商品へのアクセスのパラメータ、session_idがない場合はuser_idを使う
+type AccessRequest struct {
+	ItemID    string
+	SessionID string
+	UserID    string
+}
+
+// NewAccessRequest クエリ文字列から商品へのアクセスのパラメータを作成する
+func NewAccessRequest(parameters map[string]string) (*AccessRequest, error) {
+	b := newParameterBinder(parameters)
+	request := &AccessRequest{
+		ItemID:    b.string("item_id"),
+		SessionID: b.string("session_id"),
+		UserID:    b.string("user_id"),
+	}
+
+	b.required("item_id", request.ItemID)
+
+	if err := b.err(); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
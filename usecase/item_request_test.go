@@ -0,0 +1,154 @@
+package usecase
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/akaishi-sandbox/sam-go/domain"
+)
+
+func validationFields(t *testing.T, err error) []string {
+	t.Helper()
+	var validationError *domain.ValidationError
+	if !errors.As(err, &validationError) {
+		t.Fatalf("validation error type:%T %v", err, err)
+	}
+	fields := make([]string, 0, len(validationError.Fields))
+	for _, field := range validationError.Fields {
+		fields = append(fields, field.Field)
+	}
+	return fields
+}
+
+func TestNewSearchRequest(t *testing.T) {
+	request, err := NewSearchRequest(map[string]string{
+		"brand":     "UNIQLO,GU",
+		"min_price": "1000",
+		"max_price": "3000",
+		"highlight": "1",
+		"order":     "min-max",
+		"limit":     "20",
+	}, 36)
+	if err != nil {
+		t.Fatalf("search request error:%v", err)
+	}
+	if !reflect.DeepEqual(request.Brands, []string{"UNIQLO", "GU"}) || *request.MinPrice != 1000 || *request.MaxPrice != 3000 || !request.Highlight || *request.Limit != 20 {
+		t.Errorf("search request error:%+v", request)
+	}
+	expected := map[string]string{
+		"brand":     "UNIQLO,GU",
+		"min_price": "1000",
+		"max_price": "3000",
+		"highlight": "1",
+		"order":     "min-max",
+		"limit":     "20",
+	}
+	if parameters := request.Parameters(); !reflect.DeepEqual(parameters, expected) {
+		t.Errorf("search parameters error:%v", parameters)
+	}
+}
+
+func TestNewSearchRequestInvalid(t *testing.T) {
+	_, err := NewSearchRequest(map[string]string{
+		"min_price":     "abc",
+		"max_bmi":       "-1",
+		"discount_flag": "2",
+		"facets":        "brand,color",
+		"order":         "cheap",
+		"offset":        "-1",
+		"limit":         "101",
+		"diversify":     "gender",
+		"keywords":      "(シャツ",
+	}, 36)
+	expected := []string{"min_price", "keywords", "discount_flag", "max_bmi", "facets", "order", "offset", "limit", "diversify"}
+	if fields := validationFields(t, err); !reflect.DeepEqual(fields, expected) {
+		t.Errorf("invalid fields error:%v", fields)
+	}
+}
+
+func TestNewSearchRequestRange(t *testing.T) {
+	testCase := func(parameters map[string]string, expected []string) {
+		t.Helper()
+		_, err := NewSearchRequest(parameters, 36)
+		if fields := validationFields(t, err); !reflect.DeepEqual(fields, expected) {
+			t.Errorf("invalid fields error:%v %v", parameters, fields)
+		}
+	}
+	testCase(map[string]string{"min_price": "3000", "max_price": "1000"}, []string{"min_price"})
+	testCase(map[string]string{"min_bmi": "25", "max_bmi": "20"}, []string{"min_bmi"})
+	testCase(map[string]string{"offset": "9990", "limit": "20"}, []string{"offset"})
+	// limitを指定しない場合は既定の件数でoffsetとの合計を検証する
+	testCase(map[string]string{"offset": "9990"}, []string{"offset"})
+	testCase(map[string]string{"cursor": "invalid"}, []string{"cursor"})
+	testCase(map[string]string{"highlight": "yes"}, []string{"highlight"})
+}
+
+func TestNewRecommendRequest(t *testing.T) {
+	request, err := NewRecommendRequest(map[string]string{"item_id": "A001,A002", "fallback": "none", "height": "170", "weight": "65", "strict": "true"}, 36)
+	if err != nil {
+		t.Fatalf("recommend request error:%v", err)
+	}
+	expected := map[string]string{"item_id": "A001,A002", "fallback": "none", "height": "170", "weight": "65", "strict": "1"}
+	if parameters := request.Parameters(); !reflect.DeepEqual(parameters, expected) {
+		t.Errorf("recommend parameters error:%v", parameters)
+	}
+
+	_, err = NewRecommendRequest(map[string]string{"strategy": "random", "fallback": "popular,random", "height": "170"}, 36)
+	if fields := validationFields(t, err); !reflect.DeepEqual(fields, []string{"item_id", "strategy", "fallback", "weight"}) {
+		t.Errorf("invalid fields error:%v", fields)
+	}
+}
+
+func TestNewClassificationRequest(t *testing.T) {
+	request, err := NewClassificationRequest(map[string]string{"index": "categories", "gender": "MEN", "limit": "500"}, 100)
+	if err != nil {
+		t.Fatalf("classification request error:%v", err)
+	}
+	if request.Index != "categories" || request.Filters["gender"] != "MEN" || *request.Limit != 500 {
+		t.Errorf("classification request error:%+v", request)
+	}
+
+	_, err = NewClassificationRequest(map[string]string{"mode": "graph", "limit": "1001"}, 100)
+	if fields := validationFields(t, err); !reflect.DeepEqual(fields, []string{"index", "mode", "limit"}) {
+		t.Errorf("invalid fields error:%v", fields)
+	}
+}
+
+func TestNewSuggestRequest(t *testing.T) {
+	request, err := NewSuggestRequest(map[string]string{"keywords": "ﾃﾞﾆ", "gender": "MEN", "limit": "20"})
+	if err != nil {
+		t.Fatalf("suggest request error:%v", err)
+	}
+	expected := map[string]string{"keywords": "ﾃﾞﾆ", "gender": "MEN", "limit": "20"}
+	if parameters := request.Parameters(); !reflect.DeepEqual(parameters, expected) {
+		t.Errorf("suggest parameters error:%v", parameters)
+	}
+
+	_, err = NewSuggestRequest(map[string]string{"keywords": "　", "limit": "abc"})
+	if fields := validationFields(t, err); !reflect.DeepEqual(fields, []string{"limit", "keywords"}) {
+		t.Errorf("invalid fields error:%v", fields)
+	}
+	_, err = NewSuggestRequest(map[string]string{"keywords": "uni", "limit": "100000"})
+	if fields := validationFields(t, err); !reflect.DeepEqual(fields, []string{"limit"}) {
+		t.Errorf("invalid fields error:%v", fields)
+	}
+}
+
+func TestNewBrandRequest(t *testing.T) {
+	request, err := NewBrandRequest(map[string]string{"id": "12"})
+	if err != nil || request.ID != "12" {
+		t.Fatalf("brand request error:%+v %v", request, err)
+	}
+	_, err = NewBrandRequest(map[string]string{})
+	if fields := validationFields(t, err); !reflect.DeepEqual(fields, []string{"id"}) {
+		t.Errorf("invalid fields error:%v", fields)
+	}
+}
+
+func TestNewAccessRequest(t *testing.T) {
+	_, err := NewAccessRequest(map[string]string{"session_id": "S001"})
+	if fields := validationFields(t, err); !reflect.DeepEqual(fields, []string{"item_id"}) {
+		t.Errorf("invalid fields error:%v", fields)
+	}
+}